@@ -0,0 +1,68 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/pokt-network/pocket-runner/x/runner"
+)
+
+const configUsage = "usage: pocket-runner [-<setting> <value>...] config show"
+
+// ConfigCommand implements `pocket-runner config show`, it prints the effective config merged from runner.toml,
+// the environment and the settings flags, with its secrets redacted, and returns the exit status of the command
+func ConfigCommand(settings map[string]string, args []string) int {
+	if len(args) != 1 || args[0] != "show" {
+		log.Printf("%s\n", configUsage)
+		return 2
+	}
+	cfg, err := runner.LoadConfig(settings)
+	if err != nil {
+		log.Printf("%+v\n", err)
+		return 1
+	}
+	if err := runner.ShowConfig(os.Stdout, cfg); err != nil {
+		log.Printf("%+v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// ParseSettings reads the settings flags at the start of args, as "-key value", "-key=value", or "-key" alone for
+// toggles, and returns them keyed by setting with the remaining arguments. It stops at the first argument that is
+// not a setting, so the flags of pocket-core are passed through untouched.
+func ParseSettings(args []string) (map[string]string, []string, error) {
+	settings := make(map[string]string)
+	for len(args) > 0 {
+		arg := args[0]
+		if arg == "--" {
+			return settings, args[1:], nil
+		}
+		if !strings.HasPrefix(arg, "-") {
+			break
+		}
+		key := strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		value, hasValue := "", false
+		if i := strings.Index(key, "="); i >= 0 {
+			key, value, hasValue = key[:i], key[i+1:], true
+		}
+		setting, ok := runner.LookupSetting(key)
+		if !ok {
+			break
+		}
+		args = args[1:]
+		switch {
+		case hasValue:
+		case setting.IsBool():
+			value = "on"
+		case len(args) == 0:
+			return nil, nil, errors.Errorf("flag -%s needs a value", key)
+		default:
+			value, args = args[0], args[1:]
+		}
+		settings[key] = value
+	}
+	return settings, args, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSettings(t *testing.T) {
+	cases := map[string]struct {
+		args      []string
+		settings  map[string]string
+		rest      []string
+		expectErr bool
+	}{
+		"no settings": {
+			args:     []string{"start", "--blockTime", "1"},
+			settings: map[string]string{},
+			rest:     []string{"start", "--blockTime", "1"},
+		},
+		"settings before the command": {
+			args:     []string{"-restart.policy", "always", "--rpc.port=8081", "-backup.skip", "start", "--blockTime", "1"},
+			settings: map[string]string{"restart.policy": "always", "rpc.port": "8081", "backup.skip": "on"},
+			rest:     []string{"start", "--blockTime", "1"},
+		},
+		"pocket-core flags are passed through": {
+			args:     []string{"-rpc.host", "127.0.0.1", "--datadir", "/data", "start"},
+			settings: map[string]string{"rpc.host": "127.0.0.1"},
+			rest:     []string{"--datadir", "/data", "start"},
+		},
+		"end of the settings": {
+			args:     []string{"-name", "pocket-core", "--", "-rpc.port", "1"},
+			settings: map[string]string{"name": "pocket-core"},
+			rest:     []string{"-rpc.port", "1"},
+		},
+		"missing value": {
+			args:      []string{"-rpc.port"},
+			expectErr: true,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			settings, rest, err := ParseSettings(tc.args)
+			if tc.expectErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+			if !reflect.DeepEqual(settings, tc.settings) {
+				t.Errorf("expected settings %v, got %v", tc.settings, settings)
+			}
+			if len(rest) != len(tc.rest) || (len(rest) > 0 && !reflect.DeepEqual(rest, tc.rest)) {
+				t.Errorf("expected the arguments %v, got %v", tc.rest, rest)
+			}
+		})
+	}
+}
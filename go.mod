@@ -3,10 +3,13 @@ module github.com/pokt-network/pocket-runner
 go 1.13
 
 require (
+	github.com/pelletier/go-toml v1.9.5
 	github.com/pkg/errors v0.9.1
 	github.com/pokt-network/pocket-core v0.0.0-20200416145252-70e779aa9b45
 	github.com/pokt-network/posmint v0.0.0-20200415205759-f9fe52cadf8d
 	github.com/stretchr/testify v1.5.1
 	github.com/tendermint/tendermint v0.32.9
 	github.com/tendermint/tm-db v0.2.0
+	github.com/ulikunitz/xz v0.5.8
+	golang.org/x/crypto v0.0.0-20191206172530-e9b2fee46413
 )
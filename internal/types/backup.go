@@ -0,0 +1,60 @@
+package types
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	backupsDir        = "backups"
+	defaultDataDir    = "data"
+	defaultBackupKeep = 3
+)
+
+// BackupPolicy describes the snapshot of pocket-core's data taken before every upgrade,
+// so a bad migration can be rolled back
+type BackupPolicy struct {
+	// Skip disables the snapshot
+	Skip bool
+	// DataDir is pocket-core's data directory, defaults to $DAEMON_HOME/data
+	DataDir string
+	// Keep is how many snapshots are kept, the oldest are removed first. 0 keeps them all
+	Keep int
+}
+
+// DefaultBackupPolicy returns the policy used when nothing is configured
+func DefaultBackupPolicy() BackupPolicy {
+	return BackupPolicy{Keep: defaultBackupKeep}
+}
+
+// Validate returns an error if this policy is invalid
+func (p BackupPolicy) Validate() error {
+	if p.Keep < 0 {
+		return errors.New("the number of backups to keep must not be negative")
+	}
+	if p.DataDir != "" && !filepath.IsAbs(p.DataDir) {
+		return errors.New("DAEMON_DATA_DIR must be an absolute path")
+	}
+	return nil
+}
+
+// DataDir is pocket-core's data directory, the one backed up before an upgrade
+func (cfg *Config) DataDir() string {
+	if cfg.Backup.DataDir != "" {
+		return cfg.Backup.DataDir
+	}
+	return filepath.Join(cfg.Home, defaultDataDir)
+}
+
+// BackupsDir is the directory holding every data backup
+func (cfg *Config) BackupsDir() string {
+	return filepath.Join(cfg.Root(), backupsDir)
+}
+
+// BackupDir is where the data is backed up before upgrade is applied
+func (cfg *Config) BackupDir(upgrade *UpgradeInfo) string {
+	return filepath.Join(cfg.BackupsDir(), fmt.Sprintf("%s-%d", url.PathEscape(upgrade.Name), upgrade.Height))
+}
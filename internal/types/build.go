@@ -0,0 +1,85 @@
+package types
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultBuildMain is the main package of pocket-core, relative to the root of its source
+	DefaultBuildMain = "app/cmd/pocket_core"
+	buildDir         = "build"
+	buildLogFile     = "build.log"
+	buildInfoFile    = "build-info.json"
+)
+
+// BuildPolicy describes how upgrades are compiled when they are built from source.
+// Builds run with an isolated GOPATH, GOCACHE and HOME under the upgrade dir, so the ambient Go setup does not leak into them.
+type BuildPolicy struct {
+	// Main is the main package, relative to the root of the source
+	Main string
+	// LDFlags is passed to -ldflags, {version} and {commit} are replaced by the upgrade name and the source commit
+	LDFlags string
+	// NoTrimPath disables -trimpath, which strips the build paths from the binary
+	NoTrimPath bool
+	// GoFlags is the GOFLAGS of the build
+	GoFlags string
+}
+
+// DefaultBuildPolicy returns the policy used when nothing is configured
+func DefaultBuildPolicy() BuildPolicy {
+	return BuildPolicy{Main: DefaultBuildMain}
+}
+
+// WithDefaults returns a copy of the policy where every unset field has its default value
+func (p BuildPolicy) WithDefaults() BuildPolicy {
+	if p.Main == "" {
+		p.Main = DefaultBuildMain
+	}
+	return p
+}
+
+// Validate returns an error if this policy is invalid
+func (p BuildPolicy) Validate() error {
+	main := filepath.ToSlash(p.Main)
+	if filepath.IsAbs(p.Main) || main == ".." || strings.HasPrefix(main, "../") {
+		return errors.Errorf("DAEMON_BUILD_MAIN must be relative to the source root, got %s", p.Main)
+	}
+	return nil
+}
+
+// ExpandLDFlags fills the placeholders of the ldflags for the upgrade version and source commit
+func (p BuildPolicy) ExpandLDFlags(version, commit string) string {
+	return strings.NewReplacer("{version}", version, "{commit}", commit).Replace(p.LDFlags)
+}
+
+// BuildInfo records how the binary of an upgrade was built from source
+type BuildInfo struct {
+	Upgrade   string    `json:"upgrade"`
+	Toolchain string    `json:"toolchain"`
+	Commit    string    `json:"commit,omitempty"`
+	Main      string    `json:"main"`
+	LDFlags   string    `json:"ldflags,omitempty"`
+	TrimPath  bool      `json:"trimpath"`
+	GoFlags   string    `json:"goflags,omitempty"`
+	Binary    string    `json:"binary_sha256"`
+	BuiltAt   time.Time `json:"built_at"`
+}
+
+// BuildDir holds the isolated GOPATH and GOCACHE of the upgrade build
+func (cfg *Config) BuildDir(upgradeName string) string {
+	return filepath.Join(cfg.UpgradeDir(upgradeName), buildDir)
+}
+
+// BuildLog is the output of the last build of the upgrade
+func (cfg *Config) BuildLog(upgradeName string) string {
+	return filepath.Join(cfg.UpgradeDir(upgradeName), buildLogFile)
+}
+
+// BuildInfoFile describes the binary of the upgrade, when it was built from source
+func (cfg *Config) BuildInfoFile(upgradeName string) string {
+	return filepath.Join(cfg.UpgradeDir(upgradeName), buildInfoFile)
+}
@@ -1,9 +1,12 @@
 package types
 
 import (
+	"net"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
 )
@@ -15,14 +18,32 @@ const (
 	currentLink = "current"
 )
 
-const defaultPort = "26657"
+const (
+	defaultHost = "0.0.0.0"
+	defaultPort = "26657"
+)
+
 // Config is the information passed in to control the daemon
 type Config struct {
-	Home                string
-	Name                string
-	AllowDownload       bool
-	Port string
-	RestartAfterUpgrade bool
+	Home          string
+	Name          string
+	AllowDownload bool
+	// Host and Port locate the tendermint rpc of pocket-core
+	Host          string
+	Port          string
+	RestartPolicy RestartPolicy
+	Readiness     ReadinessProbe
+	Shutdown      ShutdownPolicy
+	Backup        BackupPolicy
+	Rollback      RollbackPolicy
+	Verify        VerifyPolicy
+	Download      DownloadPolicy
+	Build         BuildPolicy
+	Passphrase    PassphrasePolicy
+	// File is the config file the settings were read from, empty if none was read
+	File string
+	// sources records where each setting was read from, keyed by environment variable
+	sources map[string]string
 }
 
 // Root returns the root directory where all info lives
@@ -89,40 +110,280 @@ func (cfg *Config) CurrentBin() (string, error) {
 	dest = filepath.Join(dest, "bin", cfg.Name)
 	return dest, nil
 }
-func (cfg *Config)GetPort() string {
+
+// CurrentUpgrade returns the name of the upgrade the current link points to, empty when running genesis
+func (cfg *Config) CurrentUpgrade() string {
+	dest, err := os.Readlink(filepath.Join(cfg.Root(), currentLink))
+	if err != nil {
+		return ""
+	}
+	if filepath.Dir(filepath.Clean(dest)) != filepath.Join(cfg.Root(), upgradesDir) {
+		return ""
+	}
+	name, err := url.PathUnescape(filepath.Base(dest))
+	if err != nil {
+		return ""
+	}
+	return name
+}
+
+// GetHost returns the tendermint rpc host of pocket-core
+func (cfg *Config) GetHost() string {
+	if cfg.Host == "" {
+		return defaultHost
+	}
+	return cfg.Host
+}
+
+// GetPort returns the tendermint rpc port of pocket-core
+func (cfg *Config) GetPort() string {
+	if cfg.Port == "" {
+		return defaultPort
+	}
 	return cfg.Port
 }
 
-// GetConfigFromEnv will read the environmental variables into a config
+// RPCAddress returns the address of the tendermint rpc of pocket-core
+func (cfg *Config) RPCAddress() string {
+	return "tcp://" + net.JoinHostPort(cfg.GetHost(), cfg.GetPort())
+}
+
+// DefaultConfig returns the config of the binary name installed under home, with the default port and policies
+func DefaultConfig(home, name string) *Config {
+	return &Config{
+		Home:          home,
+		Name:          name,
+		Host:          defaultHost,
+		Port:          defaultPort,
+		RestartPolicy: DefaultRestartPolicy(),
+		Readiness:     DefaultReadinessProbe(),
+		Shutdown:      DefaultShutdownPolicy(),
+		Backup:        DefaultBackupPolicy(),
+		Rollback:      DefaultRollbackPolicy(),
+		Download:      DefaultDownloadPolicy(),
+		Build:         DefaultBuildPolicy(),
+		Passphrase:    DefaultPassphrasePolicy(),
+	}
+}
+
+// GetConfigFromEnv will read the config file and the environmental variables into a config
 // and then Validate it is reasonable
 func GetConfigFromEnv() (*Config, error) {
-	cfg := &Config{
-		Home: os.Getenv("DAEMON_HOME"),
-		Name: os.Getenv("DAEMON_NAME"),
-		Port: defaultPort,
+	return LoadConfig(nil)
+}
+
+// restartPolicyFromEnv overrides the restart policy with the DAEMON_RESTART_* variables
+func (cfg *Config) restartPolicyFromEnv(vars variables) error {
+	for _, layer := range vars.Layers() {
+		if err := cfg.restartModeFromEnv(layer); err != nil {
+			return err
+		}
+	}
+	if err := durationFromEnv(vars, "DAEMON_RESTART_BACKOFF", &cfg.RestartPolicy.Backoff); err != nil {
+		return err
+	}
+	if err := durationFromEnv(vars, "DAEMON_RESTART_MAX_BACKOFF", &cfg.RestartPolicy.MaxBackoff); err != nil {
+		return err
+	}
+	return intFromEnv(vars, "DAEMON_MAX_RESTARTS", &cfg.RestartPolicy.MaxRestarts)
+}
+
+// restartModeFromEnv overrides the restart mode with a single layer of variables. DAEMON_RESTART_AFTER_UPGRADE is kept
+// for compatibility: on relaunches pocket-core after upgrades and off never relaunches it, DAEMON_RESTART_POLICY prevails.
+func (cfg *Config) restartModeFromEnv(vars variables) error {
+	if vars.Get("DAEMON_RESTART_AFTER_UPGRADE") != "" {
+		var restart bool
+		if err := toggleFromEnv(vars, "DAEMON_RESTART_AFTER_UPGRADE", &restart); err != nil {
+			return err
+		}
+		if restart {
+			cfg.RestartPolicy.Mode = RestartModeAfterUpgrade
+		} else {
+			cfg.RestartPolicy.Mode = RestartModeNever
+		}
+	}
+	if mode := vars.Get("DAEMON_RESTART_POLICY"); mode != "" {
+		m, err := ParseRestartMode(mode)
+		if err != nil {
+			return errors.Wrap(err, "DAEMON_RESTART_POLICY")
+		}
+		cfg.RestartPolicy.Mode = m
+	}
+	return nil
+}
+
+// readinessFromEnv overrides the readiness probe with the DAEMON_READY_* variables
+func (cfg *Config) readinessFromEnv(vars variables) error {
+	if err := durationFromEnv(vars, "DAEMON_READY_TIMEOUT", &cfg.Readiness.Timeout); err != nil {
+		return err
 	}
-	if port := os.Getenv("TM_RPC_PORT"); port != "" {
-		cfg.Port = port
+	if err := durationFromEnv(vars, "DAEMON_READY_INTERVAL", &cfg.Readiness.Interval); err != nil {
+		return err
 	}
-	if os.Getenv("DAEMON_ALLOW_DOWNLOAD") == "on" {
-		cfg.AllowDownload = true
+	if err := durationFromEnv(vars, "DAEMON_READY_JITTER", &cfg.Readiness.Jitter); err != nil {
+		return err
+	}
+	return durationFromEnv(vars, "DAEMON_READY_REQUEST_TIMEOUT", &cfg.Readiness.RequestTimeout)
+}
+
+// shutdownFromEnv overrides the shutdown policy with the DAEMON_SHUTDOWN_* variables
+func (cfg *Config) shutdownFromEnv(vars variables) error {
+	if signal := vars.Get("DAEMON_SHUTDOWN_SIGNAL"); signal != "" {
+		sig, err := ParseShutdownSignal(signal)
+		if err != nil {
+			return errors.Wrap(err, "DAEMON_SHUTDOWN_SIGNAL")
+		}
+		cfg.Shutdown.Signal = sig
+	}
+	return durationFromEnv(vars, "DAEMON_SHUTDOWN_GRACE", &cfg.Shutdown.Grace)
+}
+
+// backupFromEnv overrides the backup policy with the DAEMON_DATA_DIR and DAEMON_*_BACKUP* variables
+func (cfg *Config) backupFromEnv(vars variables) error {
+	if err := toggleFromEnv(vars, "DAEMON_SKIP_BACKUP", &cfg.Backup.Skip); err != nil {
+		return err
 	}
-	if os.Getenv("DAEMON_RESTART_AFTER_UPGRADE") == "on" {
-		cfg.RestartAfterUpgrade = true
+	if dataDir := vars.Get("DAEMON_DATA_DIR"); dataDir != "" {
+		cfg.Backup.DataDir = dataDir
+	}
+	return intFromEnv(vars, "DAEMON_BACKUP_KEEP", &cfg.Backup.Keep)
+}
+
+// rollbackFromEnv overrides the rollback policy with the DAEMON_ROLLBACK* variables
+func (cfg *Config) rollbackFromEnv(vars variables) error {
+	enabled := !cfg.Rollback.Disabled
+	if err := toggleFromEnv(vars, "DAEMON_ROLLBACK", &enabled); err != nil {
+		return err
+	}
+	cfg.Rollback.Disabled = !enabled
+	if err := toggleFromEnv(vars, "DAEMON_ROLLBACK_RESTORE_DATA", &cfg.Rollback.RestoreData); err != nil {
+		return err
+	}
+	return durationFromEnv(vars, "DAEMON_ROLLBACK_WINDOW", &cfg.Rollback.Window)
+}
+
+// verifyFromEnv overrides the verify policy with DAEMON_UNSAFE_SKIP_CHECKSUM and DAEMON_SIGNING_KEYS
+func (cfg *Config) verifyFromEnv(vars variables) error {
+	if err := toggleFromEnv(vars, "DAEMON_UNSAFE_SKIP_CHECKSUM", &cfg.Verify.SkipChecksum); err != nil {
+		return err
+	}
+	for _, key := range strings.Split(vars.Get("DAEMON_SIGNING_KEYS"), ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			cfg.Verify.PublicKeys = append(cfg.Verify.PublicKeys, key)
+		}
+	}
+	return nil
+}
+
+// downloadFromEnv overrides the download policy with DAEMON_MIRRORS_FILE, DAEMON_*_MIRRORS, DAEMON_SKIP_COMPILE
+// and DAEMON_DOWNLOAD_IDLE_TIMEOUT.
+// The mirrors of the file replace the defaults, and the variables replace the mirrors of the file of the same layer.
+// Mirrors are applied a layer at a time, so toml release_mirrors never beat a DAEMON_MIRRORS_FILE from the environment.
+func (cfg *Config) downloadFromEnv(vars variables) error {
+	for _, layer := range vars.Layers() {
+		if err := cfg.mirrorsFromEnv(layer); err != nil {
+			return err
+		}
 	}
-	if err := cfg.Validate(); err != nil {
-		return nil, err
+	if err := toggleFromEnv(vars, "DAEMON_SKIP_COMPILE", &cfg.Download.SkipCompile); err != nil {
+		return err
 	}
-	return cfg, nil
+	return durationFromEnv(vars, "DAEMON_DOWNLOAD_IDLE_TIMEOUT", &cfg.Download.IdleTimeout)
+}
+
+// mirrorsFromEnv overrides the mirrors with a single layer of variables
+func (cfg *Config) mirrorsFromEnv(vars variables) error {
+	if file := vars.Get("DAEMON_MIRRORS_FILE"); file != "" {
+		release, source, err := ReadMirrorsFile(file)
+		if err != nil {
+			return errors.Wrap(err, "DAEMON_MIRRORS_FILE")
+		}
+		cfg.Download.ReleaseMirrors = release
+		cfg.Download.SourceMirrors = source
+	}
+	// an empty DAEMON_RELEASE_MIRRORS disables prebuilt releases
+	if mirrors, ok := vars.Lookup("DAEMON_RELEASE_MIRRORS"); ok {
+		release, err := ParseMirrors(mirrors)
+		if err != nil {
+			return errors.Wrap(err, "DAEMON_RELEASE_MIRRORS")
+		}
+		cfg.Download.ReleaseMirrors = release
+	}
+	if mirrors := vars.Get("DAEMON_SOURCE_MIRRORS"); mirrors != "" {
+		source, err := ParseMirrors(mirrors)
+		if err != nil {
+			return errors.Wrap(err, "DAEMON_SOURCE_MIRRORS")
+		}
+		cfg.Download.SourceMirrors = source
+	}
+	return nil
+}
+
+// buildFromEnv overrides the build policy with the DAEMON_BUILD_* variables
+func (cfg *Config) buildFromEnv(vars variables) error {
+	if main := vars.Get("DAEMON_BUILD_MAIN"); main != "" {
+		cfg.Build.Main = main
+	}
+	if ldflags := vars.Get("DAEMON_BUILD_LDFLAGS"); ldflags != "" {
+		cfg.Build.LDFlags = ldflags
+	}
+	trimPath := !cfg.Build.NoTrimPath
+	if err := toggleFromEnv(vars, "DAEMON_BUILD_TRIMPATH", &trimPath); err != nil {
+		return err
+	}
+	cfg.Build.NoTrimPath = !trimPath
+	if goflags := vars.Get("DAEMON_BUILD_GOFLAGS"); goflags != "" {
+		cfg.Build.GoFlags = goflags
+	}
+	return nil
+}
+
+// passphraseFromEnv overrides the passphrase policy with the DAEMON_PASSPHRASE_* variables,
+// setting DAEMON_PASSPHRASE_FILE or DAEMON_PASSPHRASE_FD implies the matching source.
+// They are applied a layer at a time, so a DAEMON_PASSPHRASE_FD from the environment never beats a -passphrase.file flag.
+func (cfg *Config) passphraseFromEnv(vars variables) error {
+	for _, layer := range vars.Layers() {
+		if err := cfg.passphraseSourceFromEnv(layer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// passphraseSourceFromEnv overrides the passphrase policy with a single layer of variables
+func (cfg *Config) passphraseSourceFromEnv(vars variables) error {
+	if file := vars.Get("DAEMON_PASSPHRASE_FILE"); file != "" {
+		cfg.Passphrase.Source = PassphraseSourceFile
+		cfg.Passphrase.File = file
+	}
+	if fd := vars.Get("DAEMON_PASSPHRASE_FD"); fd != "" {
+		n, err := strconv.Atoi(fd)
+		if err != nil {
+			return errors.Wrapf(err, "could not parse DAEMON_PASSPHRASE_FD: %s", fd)
+		}
+		cfg.Passphrase.Source = PassphraseSourceFD
+		cfg.Passphrase.FD = n
+	}
+	if source := vars.Get("DAEMON_PASSPHRASE_SOURCE"); source != "" {
+		s, err := ParsePassphraseSource(source)
+		if err != nil {
+			return errors.Wrap(err, "DAEMON_PASSPHRASE_SOURCE")
+		}
+		cfg.Passphrase.Source = s
+	}
+	return nil
 }
 
 // Validate returns an error if this config is invalid.
 // it enforces Home/upgrade_manager is a valid directory and exists,
-// and that Name is set
+// that Name is set and that every setting is within its bounds
 func (cfg *Config) Validate() error {
 	if cfg.Name == "" {
 		return errors.New("DAEMON_NAME is not set")
 	}
+	if cfg.Name != filepath.Base(cfg.Name) || cfg.Name == "." || cfg.Name == ".." {
+		return errors.Errorf("DAEMON_NAME must be a file name, got %q", cfg.Name)
+	}
 	if cfg.Home == "" {
 		return errors.New("DAEMON_HOME is not set")
 	}
@@ -140,6 +401,82 @@ func (cfg *Config) Validate() error {
 		return errors.Errorf("%s is not a directory", info.Name())
 	}
 
+	if cfg.Host != "" && !validHost(cfg.Host) {
+		return errors.Errorf("TM_RPC_HOST must be an ip address or a host name, got %q", cfg.Host)
+	}
+	if cfg.Port != "" {
+		if port, err := strconv.Atoi(cfg.Port); err != nil || port < 1 || port > 65535 {
+			return errors.Errorf("TM_RPC_PORT must be a port number, got %q", cfg.Port)
+		}
+	}
+
+	if err := cfg.RestartPolicy.Validate(); err != nil {
+		return err
+	}
+	if err := cfg.Readiness.Validate(); err != nil {
+		return err
+	}
+	if err := cfg.Shutdown.Validate(); err != nil {
+		return err
+	}
+	if err := cfg.Backup.Validate(); err != nil {
+		return err
+	}
+	if err := cfg.Rollback.Validate(); err != nil {
+		return err
+	}
+	if err := cfg.Verify.Validate(); err != nil {
+		return err
+	}
+	if err := cfg.Download.Validate(); err != nil {
+		return err
+	}
+	// upgrade plans carry no checksum, fail now rather than at the upgrade height
+	if cfg.AllowDownload && !cfg.Verify.SkipChecksum && cfg.Download.defaultMirrorsOnly() {
+		return errors.New("DAEMON_ALLOW_DOWNLOAD is on but the default GitHub source mirror publishes no SHA256SUMS, " +
+			"set DAEMON_RELEASE_MIRRORS or DAEMON_SOURCE_MIRRORS to mirrors publishing SHA256SUMS, or DAEMON_UNSAFE_SKIP_CHECKSUM=on")
+	}
+	if err := cfg.Build.Validate(); err != nil {
+		return err
+	}
+	if err := cfg.Passphrase.Validate(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// validHost reports whether host is an ip address or a host name
+func validHost(host string) bool {
+	if net.ParseIP(host) != nil {
+		return true
+	}
+	if len(host) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// SetCurrentGenesis points the current link back at the genesis binary
+func (cfg *Config) SetCurrentGenesis() error {
+	link := filepath.Join(cfg.Root(), currentLink)
+	if _, err := os.Lstat(link); err == nil {
+		if err := os.Remove(link); err != nil {
+			return errors.Wrap(err, "removing current symlink")
+		}
+	}
+	if _, err := cfg.SymLinkToGenesis(); err != nil {
+		return errors.Wrap(err, "creating current symlink")
+	}
 	return nil
 }
 
@@ -1,8 +1,11 @@
 package types
 
 import (
+	"os"
 	"path/filepath"
+	"syscall"
 	"testing"
+	"time"
 )
 
 func TestConfigPaths(t *testing.T) {
@@ -85,6 +88,46 @@ func TestValidate(t *testing.T) {
 			cfg:   Config{Home: filepath.FromSlash("/no/such/dir"), Name: "bind"},
 			valid: false,
 		},
+		"negative shutdown grace": {
+			cfg:   Config{Home: absPath, Name: "bind", Shutdown: ShutdownPolicy{Grace: -time.Second}},
+			valid: false,
+		},
+		"invalid signing key": {
+			cfg:   Config{Home: absPath, Name: "bind", Verify: VerifyPolicy{PublicKeys: []string{"not-a-key"}}},
+			valid: false,
+		},
+		"unsupported shutdown signal": {
+			cfg:   Config{Home: absPath, Name: "bind", Shutdown: ShutdownPolicy{Signal: syscall.SIGKILL}},
+			valid: false,
+		},
+		"build main outside the source": {
+			cfg:   Config{Home: absPath, Name: "bind", Build: BuildPolicy{Main: "../app/cmd/pocket_core"}},
+			valid: false,
+		},
+		"passphrase file not set": {
+			cfg:   Config{Home: absPath, Name: "bind", Passphrase: PassphrasePolicy{Source: PassphraseSourceFile}},
+			valid: false,
+		},
+		"unknown passphrase source": {
+			cfg:   Config{Home: absPath, Name: "bind", Passphrase: PassphrasePolicy{Source: "vault"}},
+			valid: false,
+		},
+		"name with a path": {
+			cfg:   Config{Home: absPath, Name: "../bind"},
+			valid: false,
+		},
+		"invalid port": {
+			cfg:   Config{Home: absPath, Name: "bind", Port: "http"},
+			valid: false,
+		},
+		"invalid host": {
+			cfg:   Config{Home: absPath, Name: "bind", Host: "node one"},
+			valid: false,
+		},
+		"mirror without version": {
+			cfg:   Config{Home: absPath, Name: "bind", Download: DownloadPolicy{SourceMirrors: []Mirror{{URL: "https://cache.example/pocket-core.zip"}}}},
+			valid: false,
+		},
 	}
 
 	for name, tc := range cases {
@@ -102,3 +145,31 @@ func TestValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateChecksums(t *testing.T) {
+	home := newTestHome(t, "")
+	defer os.RemoveAll(home)
+	cases := map[string]struct {
+		download DownloadPolicy
+		skip     bool
+		valid    bool
+	}{
+		"default mirrors":         {download: DefaultDownloadPolicy()},
+		"default mirrors, unsafe": {download: DefaultDownloadPolicy(), skip: true, valid: true},
+		"checksummed mirror": {download: DownloadPolicy{
+			SourceMirrors: []Mirror{{URL: "https://cache.example/{version}.zip"}, {URL: DefaultSourceURL}},
+		}, valid: true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cfg := Config{Home: home, Name: "bind", AllowDownload: true, Download: tc.download, Verify: VerifyPolicy{SkipChecksum: tc.skip}}
+			err := cfg.Validate()
+			if tc.valid && err != nil {
+				t.Error(err)
+			}
+			if !tc.valid && err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
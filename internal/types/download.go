@@ -0,0 +1,80 @@
+package types
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultSourceURL is where the source archives of pocket-core releases are published
+	DefaultSourceURL = "https://github.com/pokt-network/pocket-core/archive/{version}.zip"
+	// DefaultDownloadIdleTimeout aborts a download that received no data for this long
+	DefaultDownloadIdleTimeout = time.Minute
+)
+
+// DownloadPolicy describes where upgrade binaries are downloaded from when DAEMON_ALLOW_DOWNLOAD is on
+type DownloadPolicy struct {
+	// ReleaseMirrors serve the prebuilt release assets, a tar.gz or a zip holding the binary.
+	// They are tried in order, prebuilt releases are not downloaded if there are none, the default.
+	// pocket-core publishes no prebuilt assets, release mirrors point at an operator's own builds.
+	ReleaseMirrors []Mirror
+	// SourceMirrors serve the zipped source of the upgrade, they are tried in order
+	SourceMirrors []Mirror
+	// SkipCompile disables the fallback to compiling the upgrade from source
+	SkipCompile bool
+	// IdleTimeout aborts a download that received no data for this long, the download is resumed if possible
+	IdleTimeout time.Duration
+}
+
+// DefaultDownloadPolicy returns the policy used when nothing is configured
+func DefaultDownloadPolicy() DownloadPolicy {
+	return DownloadPolicy{
+		SourceMirrors: []Mirror{{URL: DefaultSourceURL, Timeout: DefaultMirrorTimeout}},
+		IdleTimeout:   DefaultDownloadIdleTimeout,
+	}
+}
+
+// WithDefaults returns a copy of the policy where every unset field has its default value
+func (p DownloadPolicy) WithDefaults() DownloadPolicy {
+	if p.IdleTimeout == 0 {
+		p.IdleTimeout = DefaultDownloadIdleTimeout
+	}
+	return p
+}
+
+// defaultMirrorsOnly reports whether the default GitHub source archive is the only mirror used by the policy,
+// GitHub publishes no SHA256SUMS for it.
+func (p DownloadPolicy) defaultMirrorsOnly() bool {
+	mirrors := p.ReleaseMirrors
+	if !p.SkipCompile {
+		mirrors = append(append([]Mirror{}, mirrors...), p.SourceMirrors...)
+	}
+	for _, m := range mirrors {
+		if m.URL != DefaultSourceURL {
+			return false
+		}
+	}
+	return len(mirrors) > 0
+}
+
+// Validate returns an error if this policy is invalid
+func (p DownloadPolicy) Validate() error {
+	if len(p.ReleaseMirrors) == 0 && p.SkipCompile {
+		return errors.New("DAEMON_SKIP_COMPILE is on but no release mirror is set, upgrades cannot be downloaded")
+	}
+	if p.IdleTimeout < 0 {
+		return errors.Errorf("DAEMON_DOWNLOAD_IDLE_TIMEOUT must not be negative, got %s", p.IdleTimeout)
+	}
+	for _, m := range p.ReleaseMirrors {
+		if err := m.Validate(); err != nil {
+			return errors.Wrap(err, "DAEMON_RELEASE_MIRRORS")
+		}
+	}
+	for _, m := range p.SourceMirrors {
+		if err := m.Validate(); err != nil {
+			return errors.Wrap(err, "DAEMON_SOURCE_MIRRORS")
+		}
+	}
+	return nil
+}
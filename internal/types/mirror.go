@@ -0,0 +1,141 @@
+package types
+
+import (
+	"bufio"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultMirrorTimeout bounds a download from a mirror that does not configure its own timeout
+const DefaultMirrorTimeout = 10 * time.Minute
+
+// mirror kinds of a mirrors file
+const (
+	releaseMirror = "release"
+	sourceMirror  = "source"
+)
+
+// Mirror is a location upgrades are downloaded from
+type Mirror struct {
+	// URL is the url template of the artifact, it may contain {name}, {version}, {os} and {arch}
+	URL string
+	// Timeout bounds the download from this mirror, DefaultMirrorTimeout is used if it is zero
+	Timeout time.Duration
+}
+
+// WithDefaults returns a copy of the mirror where every unset field has its default value
+func (m Mirror) WithDefaults() Mirror {
+	if m.Timeout == 0 {
+		m.Timeout = DefaultMirrorTimeout
+	}
+	return m
+}
+
+// Link fills the placeholders of the mirror url for the binary name and version, on this OS and architecture
+func (m Mirror) Link(name, version string) string {
+	return ExpandURL(m.URL, name, version)
+}
+
+// ExpandURL fills the placeholders of template for the binary name and version, on this OS and architecture
+func ExpandURL(template, name, version string) string {
+	return strings.NewReplacer(
+		"{name}", name,
+		"{version}", version,
+		"{os}", runtime.GOOS,
+		"{arch}", runtime.GOARCH,
+	).Replace(template)
+}
+
+// Validate returns an error if this mirror is invalid
+func (m Mirror) Validate() error {
+	if !strings.Contains(m.URL, "{version}") {
+		return errors.Errorf("mirror %s must contain {version}", m.URL)
+	}
+	u, err := url.Parse(m.URL)
+	if err != nil {
+		return errors.Wrapf(err, "invalid mirror %s", m.URL)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.Errorf("mirror %s must be an http or https url", m.URL)
+	}
+	if m.Timeout < 0 {
+		return errors.Errorf("timeout of mirror %s must not be negative, got %s", m.URL, m.Timeout)
+	}
+	return nil
+}
+
+// ParseMirror parses "<url template> [timeout]", the timeout defaults to DefaultMirrorTimeout
+func ParseMirror(s string) (Mirror, error) {
+	fields := strings.Fields(s)
+	switch len(fields) {
+	case 1:
+		return Mirror{URL: fields[0], Timeout: DefaultMirrorTimeout}, nil
+	case 2:
+		timeout, err := time.ParseDuration(fields[1])
+		if err != nil {
+			return Mirror{}, errors.Wrapf(err, "invalid timeout of mirror %s", fields[0])
+		}
+		return Mirror{URL: fields[0], Timeout: timeout}, nil
+	default:
+		return Mirror{}, errors.Errorf("invalid mirror %q, expected \"<url template> [timeout]\"", s)
+	}
+}
+
+// ParseMirrors parses a comma separated list of mirrors
+func ParseMirrors(s string) ([]Mirror, error) {
+	var mirrors []Mirror
+	for _, spec := range strings.Split(s, ",") {
+		if spec = strings.TrimSpace(spec); spec == "" {
+			continue
+		}
+		m, err := ParseMirror(spec)
+		if err != nil {
+			return nil, err
+		}
+		mirrors = append(mirrors, m)
+	}
+	return mirrors, nil
+}
+
+// ReadMirrorsFile reads the release and source mirrors listed in file, one per line:
+// "release <url template> [timeout]" or "source <url template> [timeout]".
+// Blank lines and lines starting with # are ignored.
+func ReadMirrorsFile(file string) (release, source []Mirror, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "cannot open mirrors file")
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, nil, errors.Errorf("%s:%d: expected \"<release|source> <url template> [timeout]\"", file, n)
+		}
+		m, err := ParseMirror(strings.Join(fields[1:], " "))
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "%s:%d", file, n)
+		}
+		switch fields[0] {
+		case releaseMirror:
+			release = append(release, m)
+		case sourceMirror:
+			source = append(source, m)
+		default:
+			return nil, nil, errors.Errorf("%s:%d: unknown mirror kind %q, expected release or source", file, n, fields[0])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, errors.Wrap(err, "cannot read mirrors file")
+	}
+	return release, source, nil
+}
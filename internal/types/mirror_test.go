@@ -0,0 +1,117 @@
+package types
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestParseMirrors(t *testing.T) {
+	cases := map[string]struct {
+		mirrors   string
+		expect    []Mirror
+		expectErr bool
+	}{
+		"default timeout": {
+			mirrors: "https://cache.example/{version}.zip",
+			expect:  []Mirror{{URL: "https://cache.example/{version}.zip", Timeout: DefaultMirrorTimeout}},
+		},
+		"timeouts": {
+			mirrors: " https://cache.example/{version}.zip 30s, https://github.com/pokt-network/pocket-core/archive/{version}.zip 5m ,",
+			expect: []Mirror{
+				{URL: "https://cache.example/{version}.zip", Timeout: 30 * time.Second},
+				{URL: "https://github.com/pokt-network/pocket-core/archive/{version}.zip", Timeout: 5 * time.Minute},
+			},
+		},
+		"empty":         {mirrors: ""},
+		"bad timeout":   {mirrors: "https://cache.example/{version}.zip soon", expectErr: true},
+		"too many args": {mirrors: "https://cache.example/{version}.zip 30s 1m", expectErr: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			mirrors, err := ParseMirrors(tc.mirrors)
+			if tc.expectErr {
+				if err == nil {
+					t.Errorf("expected an error for %q", tc.mirrors)
+				}
+				return
+			}
+			if err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+			if !reflect.DeepEqual(mirrors, tc.expect) {
+				t.Errorf("expected %+v, got %+v", tc.expect, mirrors)
+			}
+		})
+	}
+}
+
+func TestMirrorLink(t *testing.T) {
+	m := Mirror{URL: "https://cache.example/{name}/{version}/{name}_{os}_{arch}.tar.gz"}
+	expect := "https://cache.example/pocket/RC-0.3.0/pocket_" + runtime.GOOS + "_" + runtime.GOARCH + ".tar.gz"
+	if link := m.Link("pocket", "RC-0.3.0"); link != expect {
+		t.Errorf("expected %s, got %s", expect, link)
+	}
+}
+
+func TestReadMirrorsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mirrors")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "mirrors")
+	content := `# internal artifact cache first
+release https://cache.example/{version}/{name}_{version}_{os}_{arch}.tar.gz 1m
+release	https://github.com/pokt-network/pocket-core/releases/download/{version}/{name}_{os}_{arch}.tar.gz
+
+source https://cache.example/{version}.zip 2m
+`
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	release, source, err := ReadMirrorsFile(file)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	expectRelease := []Mirror{
+		{URL: "https://cache.example/{version}/{name}_{version}_{os}_{arch}.tar.gz", Timeout: time.Minute},
+		{URL: "https://github.com/pokt-network/pocket-core/releases/download/{version}/{name}_{os}_{arch}.tar.gz", Timeout: DefaultMirrorTimeout},
+	}
+	if !reflect.DeepEqual(release, expectRelease) {
+		t.Errorf("expected release mirrors %+v, got %+v", expectRelease, release)
+	}
+	expectSource := []Mirror{{URL: "https://cache.example/{version}.zip", Timeout: 2 * time.Minute}}
+	if !reflect.DeepEqual(source, expectSource) {
+		t.Errorf("expected source mirrors %+v, got %+v", expectSource, source)
+	}
+
+	if err := ioutil.WriteFile(file, []byte("binary https://cache.example/{version}.zip\n"), 0644); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if _, _, err := ReadMirrorsFile(file); err == nil {
+		t.Error("expected an error for an unknown mirror kind")
+	}
+}
+
+func TestDefaultDownloadPolicy(t *testing.T) {
+	policy := DefaultDownloadPolicy()
+	// pocket-core publishes no prebuilt assets, release mirrors are opt-in
+	if len(policy.ReleaseMirrors) != 0 {
+		t.Errorf("expected no release mirror by default, got %+v", policy.ReleaseMirrors)
+	}
+	if len(policy.SourceMirrors) != 1 || policy.SourceMirrors[0].URL != DefaultSourceURL {
+		t.Errorf("expected the source archives of GitHub by default, got %+v", policy.SourceMirrors)
+	}
+}
@@ -0,0 +1,64 @@
+package types
+
+import (
+	"github.com/pkg/errors"
+)
+
+// PassphraseSource is where the passphrase pocket-core asks for on startup comes from
+type PassphraseSource string
+
+const (
+	// PassphraseSourceInherit lets pocket-core read the stdin of the runner, an operator has to type the passphrase on every launch
+	PassphraseSourceInherit PassphraseSource = "inherit"
+	// PassphraseSourceFile reads the passphrase from File on every launch
+	PassphraseSourceFile PassphraseSource = "file"
+	// PassphraseSourceEnv reads the passphrase from PassphraseEnv once, the variable is removed from the environment
+	PassphraseSourceEnv PassphraseSource = "env"
+	// PassphraseSourceFD reads the passphrase once from FD, a file descriptor inherited by the runner
+	PassphraseSourceFD PassphraseSource = "fd"
+	// PassphraseSourcePrompt asks for the passphrase once on the terminal of the runner
+	PassphraseSourcePrompt PassphraseSource = "prompt"
+)
+
+// PassphraseEnv holds the passphrase with the env source
+const PassphraseEnv = "DAEMON_PASSPHRASE"
+
+// PassphrasePolicy describes how the passphrase is fed to pocket-core. Except with the inherit source, the runner
+// writes it to the stdin of every pocket-core it launches, so relaunches after an upgrade do not wait for an operator.
+type PassphrasePolicy struct {
+	Source PassphraseSource
+	// File holds the passphrase with the file source
+	File string
+	// FD is the descriptor holding the passphrase with the fd source
+	FD int
+}
+
+// DefaultPassphrasePolicy returns the policy used when nothing is configured
+func DefaultPassphrasePolicy() PassphrasePolicy {
+	return PassphrasePolicy{Source: PassphraseSourceInherit}
+}
+
+// ParsePassphraseSource converts a string into a PassphraseSource, returns an error if the source is unknown
+func ParsePassphraseSource(s string) (PassphraseSource, error) {
+	switch source := PassphraseSource(s); source {
+	case PassphraseSourceInherit, PassphraseSourceFile, PassphraseSourceEnv, PassphraseSourceFD, PassphraseSourcePrompt:
+		return source, nil
+	}
+	return "", errors.Errorf("unknown passphrase source %q, valid values are 'inherit', 'file', 'env', 'fd', 'prompt'", s)
+}
+
+// Validate returns an error if this policy is invalid
+func (p PassphrasePolicy) Validate() error {
+	if p.Source != "" {
+		if _, err := ParsePassphraseSource(string(p.Source)); err != nil {
+			return err
+		}
+	}
+	if p.Source == PassphraseSourceFile && p.File == "" {
+		return errors.New("DAEMON_PASSPHRASE_FILE must be set with the file passphrase source")
+	}
+	if p.FD < 0 {
+		return errors.Errorf("passphrase fd must not be negative, got %d", p.FD)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package types
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultReadyTimeout        = 5 * time.Minute
+	defaultReadyInterval       = time.Second
+	defaultReadyJitter         = 500 * time.Millisecond
+	defaultReadyRequestTimeout = 5 * time.Second
+)
+
+// ReadinessProbe describes how long and how often the runner polls pocket-core's rpc before listening to it
+type ReadinessProbe struct {
+	// Timeout is how long to wait for pocket-core to become ready before giving up
+	Timeout time.Duration
+	// Interval is the delay between two probes
+	Interval time.Duration
+	// Jitter is the maximum random delay added to Interval
+	Jitter time.Duration
+	// RequestTimeout bounds a single /status request
+	RequestTimeout time.Duration
+}
+
+// DefaultReadinessProbe returns the probe used when nothing is configured
+func DefaultReadinessProbe() ReadinessProbe {
+	return ReadinessProbe{
+		Timeout:        defaultReadyTimeout,
+		Interval:       defaultReadyInterval,
+		Jitter:         defaultReadyJitter,
+		RequestTimeout: defaultReadyRequestTimeout,
+	}
+}
+
+// WithDefaults returns a copy of the probe where every unset field has its default value
+func (p ReadinessProbe) WithDefaults() ReadinessProbe {
+	def := DefaultReadinessProbe()
+	if p.Timeout == 0 {
+		p.Timeout = def.Timeout
+	}
+	if p.Interval == 0 {
+		p.Interval = def.Interval
+	}
+	if p.RequestTimeout == 0 {
+		p.RequestTimeout = def.RequestTimeout
+	}
+	return p
+}
+
+// Validate returns an error if this probe is invalid
+func (p ReadinessProbe) Validate() error {
+	if p.Timeout < 0 || p.Interval < 0 || p.Jitter < 0 || p.RequestTimeout < 0 {
+		return errors.New("readiness probe durations must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package types
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RestartMode controls when the runner relaunches pocket-core
+type RestartMode string
+
+const (
+	// RestartModeNever never relaunches pocket-core, the runner exits once the process is gone
+	RestartModeNever RestartMode = "never"
+	// RestartModeAfterUpgrade relaunches pocket-core only after an upgrade has been applied
+	RestartModeAfterUpgrade RestartMode = "after-upgrade"
+	// RestartModeOnFailure relaunches after upgrades and whenever pocket-core exits with an error
+	RestartModeOnFailure RestartMode = "on-failure"
+	// RestartModeAlways relaunches after upgrades and on every exit
+	RestartModeAlways RestartMode = "always"
+)
+
+const (
+	defaultRestartBackoff    = time.Second
+	defaultRestartMaxBackoff = time.Minute
+	defaultMaxRestarts       = 5
+)
+
+// RestartPolicy describes when and how often pocket-core may be relaunched
+type RestartPolicy struct {
+	Mode RestartMode
+	// Backoff is the delay before the first restart after a failure, it doubles on each consecutive failure
+	Backoff time.Duration
+	// MaxBackoff caps the exponential backoff
+	MaxBackoff time.Duration
+	// MaxRestarts is the number of failure restarts allowed before giving up, 0 means unlimited
+	MaxRestarts int
+}
+
+// DefaultRestartPolicy returns the policy used when nothing is configured
+func DefaultRestartPolicy() RestartPolicy {
+	return RestartPolicy{
+		Mode:        RestartModeAfterUpgrade,
+		Backoff:     defaultRestartBackoff,
+		MaxBackoff:  defaultRestartMaxBackoff,
+		MaxRestarts: defaultMaxRestarts,
+	}
+}
+
+// ParseRestartMode converts a string into a RestartMode, returns an error if the mode is unknown
+func ParseRestartMode(s string) (RestartMode, error) {
+	switch mode := RestartMode(s); mode {
+	case RestartModeNever, RestartModeAfterUpgrade, RestartModeOnFailure, RestartModeAlways:
+		return mode, nil
+	}
+	return "", errors.Errorf("unknown restart policy %q, valid values are 'never', 'after-upgrade', 'on-failure', 'always'", s)
+}
+
+// Validate returns an error if this policy is invalid
+func (p RestartPolicy) Validate() error {
+	if p.Mode != "" {
+		if _, err := ParseRestartMode(string(p.Mode)); err != nil {
+			return err
+		}
+	}
+	if p.Backoff < 0 || p.MaxBackoff < 0 {
+		return errors.New("restart backoff must not be negative")
+	}
+	if p.MaxBackoff != 0 && p.MaxBackoff < p.Backoff {
+		return errors.Errorf("restart max backoff %s is lower than the initial backoff %s", p.MaxBackoff, p.Backoff)
+	}
+	if p.MaxRestarts < 0 {
+		return errors.New("max restarts must not be negative")
+	}
+	return nil
+}
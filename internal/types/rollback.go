@@ -0,0 +1,52 @@
+package types
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const defaultRollbackWindow = 30 * time.Minute
+
+// RollbackPolicy describes the health window that follows an upgrade. If the upgraded binary exits or
+// pocket-core does not produce a new block within Window, the previous binary is restored.
+type RollbackPolicy struct {
+	// Disabled keeps the upgraded binary no matter how it behaves
+	Disabled bool
+	// Window is how long the upgraded binary has to produce a block past the upgrade height
+	Window time.Duration
+	// RestoreData also restores the data backed up before the upgrade
+	RestoreData bool
+}
+
+// DefaultRollbackPolicy returns the policy used when nothing is configured
+func DefaultRollbackPolicy() RollbackPolicy {
+	return RollbackPolicy{Window: defaultRollbackWindow}
+}
+
+// WithDefaults returns a copy of the policy where every unset field has its default value
+func (p RollbackPolicy) WithDefaults() RollbackPolicy {
+	if p.Window == 0 {
+		p.Window = defaultRollbackWindow
+	}
+	return p
+}
+
+// Validate returns an error if this policy is invalid
+func (p RollbackPolicy) Validate() error {
+	if p.Window < 0 {
+		return errors.New("rollback window must not be negative")
+	}
+	return nil
+}
+
+// RollbackPoint is what an upgrade replaced, it is enough to undo the upgrade
+type RollbackPoint struct {
+	// Previous is the upgrade that was running before, empty for genesis
+	Previous string `json:"previous"`
+	// Backup is the data backup taken before switching binaries, empty if none was taken
+	Backup string `json:"backup,omitempty"`
+	// Height is the latest height pocket-core reached before switching binaries,
+	// the upgraded binary has to produce a block past it to prove healthy
+	Height int64 `json:"height,omitempty"`
+}
@@ -0,0 +1,446 @@
+package types
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml"
+	"github.com/pkg/errors"
+)
+
+// configFileName is the config file of the runner, read from the root directory unless DAEMON_CONFIG is set
+const configFileName = "runner.toml"
+
+// sources of a setting, reported by ShowConfig
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// settingKind is the TOML type of a setting
+type settingKind int
+
+const (
+	kindString settingKind = iota
+	kindDuration
+	kindInt
+	kindBool
+	kindList
+)
+
+// Setting is an option of the runner. It is set by Key in runner.toml, overridden by the Env variable,
+// which is overridden by the -Key flag.
+type Setting struct {
+	Key   string
+	Env   string
+	Usage string
+	kind  settingKind
+	// value renders the setting of a config in the syntax of its variable, settings without one are not shown
+	value func(cfg *Config) string
+	// secret settings hold urls whose credentials are redacted when shown
+	secret bool
+	// noFile settings locate the config file, they cannot be set in it
+	noFile bool
+}
+
+// IsBool reports whether the setting is an on/off toggle
+func (s Setting) IsBool() bool {
+	return s.kind == kindBool
+}
+
+// Settings lists every setting of the runner, in the order they are shown
+var Settings = []Setting{
+	{Key: "home", Env: "DAEMON_HOME", Usage: "directory holding the runner directory", noFile: true,
+		value: func(cfg *Config) string { return cfg.Home }},
+	{Key: "config", Env: "DAEMON_CONFIG", Usage: "config file, defaults to $DAEMON_HOME/runner/runner.toml", noFile: true,
+		value: func(cfg *Config) string { return cfg.File }},
+	{Key: "name", Env: "DAEMON_NAME", Usage: "name of the pocket-core binary",
+		value: func(cfg *Config) string { return cfg.Name }},
+	{Key: "rpc.host", Env: "TM_RPC_HOST", Usage: "host of the pocket-core tendermint rpc",
+		value: func(cfg *Config) string { return cfg.GetHost() }},
+	{Key: "rpc.port", Env: "TM_RPC_PORT", Usage: "port of the pocket-core tendermint rpc", kind: kindInt,
+		value: func(cfg *Config) string { return cfg.GetPort() }},
+	{Key: "download.allow", Env: "DAEMON_ALLOW_DOWNLOAD", Usage: "download upgrades that are not installed", kind: kindBool,
+		value: func(cfg *Config) string { return toggle(cfg.AllowDownload) }},
+	{Key: "download.release_mirrors", Env: "DAEMON_RELEASE_MIRRORS", Usage: "comma separated mirrors of the release asset", kind: kindList, secret: true,
+		value: func(cfg *Config) string { return formatMirrors(cfg.Download.ReleaseMirrors) }},
+	{Key: "download.source_mirrors", Env: "DAEMON_SOURCE_MIRRORS", Usage: "comma separated mirrors of the source archive", kind: kindList, secret: true,
+		value: func(cfg *Config) string { return formatMirrors(cfg.Download.SourceMirrors) }},
+	// the mirrors of the file are shown with the mirrors above
+	{Key: "download.mirrors_file", Env: "DAEMON_MIRRORS_FILE", Usage: "file listing the release and source mirrors"},
+	{Key: "download.skip_compile", Env: "DAEMON_SKIP_COMPILE", Usage: "never build upgrades from source", kind: kindBool,
+		value: func(cfg *Config) string { return toggle(cfg.Download.SkipCompile) }},
+	{Key: "download.idle_timeout", Env: "DAEMON_DOWNLOAD_IDLE_TIMEOUT", Usage: "abort downloads idle for this long", kind: kindDuration,
+		value: func(cfg *Config) string { return cfg.Download.WithDefaults().IdleTimeout.String() }},
+	{Key: "restart.policy", Env: "DAEMON_RESTART_POLICY", Usage: "never, after-upgrade, on-failure or always",
+		value: func(cfg *Config) string { return string(cfg.RestartPolicy.Mode) }},
+	{Key: "restart.backoff", Env: "DAEMON_RESTART_BACKOFF", Usage: "delay before the first restart after a failure", kind: kindDuration,
+		value: func(cfg *Config) string { return cfg.RestartPolicy.Backoff.String() }},
+	{Key: "restart.max_backoff", Env: "DAEMON_RESTART_MAX_BACKOFF", Usage: "cap of the restart backoff", kind: kindDuration,
+		value: func(cfg *Config) string { return cfg.RestartPolicy.MaxBackoff.String() }},
+	{Key: "restart.max_restarts", Env: "DAEMON_MAX_RESTARTS", Usage: "failure restarts allowed before giving up, 0 is unlimited", kind: kindInt,
+		value: func(cfg *Config) string { return strconv.Itoa(cfg.RestartPolicy.MaxRestarts) }},
+	{Key: "readiness.timeout", Env: "DAEMON_READY_TIMEOUT", Usage: "how long pocket-core has to answer on its rpc", kind: kindDuration,
+		value: func(cfg *Config) string { return cfg.Readiness.WithDefaults().Timeout.String() }},
+	{Key: "readiness.interval", Env: "DAEMON_READY_INTERVAL", Usage: "delay between readiness checks", kind: kindDuration,
+		value: func(cfg *Config) string { return cfg.Readiness.WithDefaults().Interval.String() }},
+	{Key: "readiness.jitter", Env: "DAEMON_READY_JITTER", Usage: "random delay added to the readiness interval", kind: kindDuration,
+		value: func(cfg *Config) string { return cfg.Readiness.Jitter.String() }},
+	{Key: "readiness.request_timeout", Env: "DAEMON_READY_REQUEST_TIMEOUT", Usage: "timeout of a readiness check", kind: kindDuration,
+		value: func(cfg *Config) string { return cfg.Readiness.WithDefaults().RequestTimeout.String() }},
+	{Key: "shutdown.signal", Env: "DAEMON_SHUTDOWN_SIGNAL", Usage: "SIGTERM or SIGINT",
+		value: func(cfg *Config) string { return signalName(cfg.Shutdown.WithDefaults().Signal) }},
+	{Key: "shutdown.grace", Env: "DAEMON_SHUTDOWN_GRACE", Usage: "how long pocket-core has to exit before it is killed", kind: kindDuration,
+		value: func(cfg *Config) string { return cfg.Shutdown.WithDefaults().Grace.String() }},
+	{Key: "backup.skip", Env: "DAEMON_SKIP_BACKUP", Usage: "do not back up the data directory before upgrades", kind: kindBool,
+		value: func(cfg *Config) string { return toggle(cfg.Backup.Skip) }},
+	{Key: "backup.data_dir", Env: "DAEMON_DATA_DIR", Usage: "data directory of pocket-core",
+		value: func(cfg *Config) string { return cfg.DataDir() }},
+	{Key: "backup.keep", Env: "DAEMON_BACKUP_KEEP", Usage: "snapshots kept, 0 keeps them all", kind: kindInt,
+		value: func(cfg *Config) string { return strconv.Itoa(cfg.Backup.Keep) }},
+	{Key: "rollback.enabled", Env: "DAEMON_ROLLBACK", Usage: "roll back upgrades that fail their health window", kind: kindBool,
+		value: func(cfg *Config) string { return toggle(!cfg.Rollback.Disabled) }},
+	{Key: "rollback.window", Env: "DAEMON_ROLLBACK_WINDOW", Usage: "health window of an upgrade", kind: kindDuration,
+		value: func(cfg *Config) string { return cfg.Rollback.WithDefaults().Window.String() }},
+	{Key: "rollback.restore_data", Env: "DAEMON_ROLLBACK_RESTORE_DATA", Usage: "restore the data backed up before a rolled back upgrade", kind: kindBool,
+		value: func(cfg *Config) string { return toggle(cfg.Rollback.RestoreData) }},
+	{Key: "verify.skip_checksum", Env: "DAEMON_UNSAFE_SKIP_CHECKSUM", Usage: "accept artifacts without a checksum, unsafe", kind: kindBool,
+		value: func(cfg *Config) string { return toggle(cfg.Verify.SkipChecksum) }},
+	{Key: "verify.signing_keys", Env: "DAEMON_SIGNING_KEYS", Usage: "comma separated minisign public keys trusted to sign releases", kind: kindList,
+		value: func(cfg *Config) string { return strings.Join(cfg.Verify.PublicKeys, ",") }},
+	{Key: "build.main", Env: "DAEMON_BUILD_MAIN", Usage: "main package of source builds",
+		value: func(cfg *Config) string { return cfg.Build.Main }},
+	{Key: "build.ldflags", Env: "DAEMON_BUILD_LDFLAGS", Usage: "-ldflags of source builds",
+		value: func(cfg *Config) string { return cfg.Build.LDFlags }},
+	{Key: "build.trimpath", Env: "DAEMON_BUILD_TRIMPATH", Usage: "build from source with -trimpath", kind: kindBool,
+		value: func(cfg *Config) string { return toggle(!cfg.Build.NoTrimPath) }},
+	{Key: "build.goflags", Env: "DAEMON_BUILD_GOFLAGS", Usage: "GOFLAGS of source builds",
+		value: func(cfg *Config) string { return cfg.Build.GoFlags }},
+	{Key: "passphrase.source", Env: "DAEMON_PASSPHRASE_SOURCE", Usage: "inherit, file, env, fd or prompt",
+		value: func(cfg *Config) string { return string(cfg.Passphrase.Source) }},
+	{Key: "passphrase.file", Env: "DAEMON_PASSPHRASE_FILE", Usage: "file holding the passphrase",
+		value: func(cfg *Config) string { return cfg.Passphrase.File }},
+	{Key: "passphrase.fd", Env: "DAEMON_PASSPHRASE_FD", Usage: "descriptor holding the passphrase", kind: kindInt,
+		value: func(cfg *Config) string { return strconv.Itoa(cfg.Passphrase.FD) }},
+}
+
+// LookupSetting returns the setting set by key in the config file and by the -key flag
+func LookupSetting(key string) (Setting, bool) {
+	for _, s := range Settings {
+		if s.Key == key {
+			return s, true
+		}
+	}
+	return Setting{}, false
+}
+
+// variables looks settings up by their environment variable: flags override the environment,
+// which overrides the config file. The layer each setting was read from is recorded in sources.
+type variables struct {
+	file    map[string]string
+	flags   map[string]string
+	sources map[string]string
+	// only restricts the lookups to a single layer, see Layers
+	only string
+}
+
+// layer is where variables are read from, the config file, the environment or the flags
+type layer struct {
+	source string
+	lookup func(string) (string, bool)
+}
+
+// layers returns the layers of v, highest first
+func (v variables) layers() []layer {
+	layers := []layer{
+		{SourceFlag, func(name string) (string, bool) { value, ok := v.flags[name]; return value, ok }},
+		{SourceEnv, os.LookupEnv},
+		{SourceFile, func(name string) (string, bool) { value, ok := v.file[name]; return value, ok }},
+	}
+	for _, l := range layers {
+		if l.source == v.only {
+			return []layer{l}
+		}
+	}
+	return layers
+}
+
+// Layers returns a view of each layer of v alone, lowest first. Settings that select between each other,
+// like the passphrase file and descriptor, are applied a layer at a time, so a higher layer always prevails.
+func (v variables) Layers() []variables {
+	views := []variables{}
+	for _, source := range []string{SourceFile, SourceEnv, SourceFlag} {
+		view := v
+		view.only = source
+		views = append(views, view)
+	}
+	return views
+}
+
+// Lookup returns the value of the named variable and whether it is set, even if it is empty
+func (v variables) Lookup(name string) (string, bool) {
+	for _, layer := range v.layers() {
+		if value, ok := layer.lookup(name); ok {
+			v.sources[name] = layer.source
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// Get returns the first value of the named variable that is not empty, empty if there is none
+func (v variables) Get(name string) string {
+	for _, layer := range v.layers() {
+		if value, _ := layer.lookup(name); value != "" {
+			v.sources[name] = layer.source
+			return value
+		}
+	}
+	return ""
+}
+
+// ConfigFile is the default location of the config file
+func (cfg *Config) ConfigFile() string {
+	return filepath.Join(cfg.Root(), configFileName)
+}
+
+// Source returns where the setting with the environment variable name was read from
+func (cfg *Config) Source(name string) string {
+	if source, ok := cfg.sources[name]; ok {
+		return source
+	}
+	return SourceDefault
+}
+
+// LoadConfig merges the config file, the environment variables and the flags, keyed by setting, into a config
+// and validates it. The config file is $DAEMON_HOME/runner/runner.toml unless DAEMON_CONFIG is set, the default one may be missing.
+func LoadConfig(flags map[string]string) (*Config, error) {
+	vars := variables{flags: make(map[string]string), sources: make(map[string]string)}
+	for key, value := range flags {
+		s, ok := LookupSetting(key)
+		if !ok {
+			return nil, errors.Errorf("unknown setting %q", key)
+		}
+		vars.flags[s.Env] = value
+	}
+	cfg := DefaultConfig(vars.Get("DAEMON_HOME"), "")
+	cfg.sources = vars.sources
+	file := vars.Get("DAEMON_CONFIG")
+	explicit := file != ""
+	if !explicit && cfg.Home != "" {
+		file = cfg.ConfigFile()
+	}
+	if file != "" {
+		values, err := ReadConfigFile(file)
+		switch {
+		case err == nil:
+			vars.file = values
+			cfg.File = file
+		case explicit || !os.IsNotExist(errors.Cause(err)):
+			return nil, err
+		}
+	}
+
+	cfg.Name = vars.Get("DAEMON_NAME")
+	if host := vars.Get("TM_RPC_HOST"); host != "" {
+		cfg.Host = host
+	}
+	if port := vars.Get("TM_RPC_PORT"); port != "" {
+		cfg.Port = port
+	}
+	if err := toggleFromEnv(vars, "DAEMON_ALLOW_DOWNLOAD", &cfg.AllowDownload); err != nil {
+		return nil, err
+	}
+	if err := cfg.restartPolicyFromEnv(vars); err != nil {
+		return nil, err
+	}
+	if err := cfg.readinessFromEnv(vars); err != nil {
+		return nil, err
+	}
+	if err := cfg.shutdownFromEnv(vars); err != nil {
+		return nil, err
+	}
+	if err := cfg.backupFromEnv(vars); err != nil {
+		return nil, err
+	}
+	if err := cfg.rollbackFromEnv(vars); err != nil {
+		return nil, err
+	}
+	if err := cfg.verifyFromEnv(vars); err != nil {
+		return nil, err
+	}
+	if err := cfg.downloadFromEnv(vars); err != nil {
+		return nil, err
+	}
+	if err := cfg.buildFromEnv(vars); err != nil {
+		return nil, err
+	}
+	if err := cfg.passphraseFromEnv(vars); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// ReadConfigFile reads a runner.toml, returns its settings keyed by environment variable in the syntax of the variables
+func ReadConfigFile(file string) (map[string]string, error) {
+	tree, err := toml.LoadFile(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read config file")
+	}
+	flat := make(map[string]interface{})
+	flattenTree("", tree.ToMap(), flat)
+	values := make(map[string]string, len(flat))
+	for key, value := range flat {
+		s, ok := LookupSetting(key)
+		if !ok || s.noFile {
+			return nil, errors.Errorf("%s: unknown setting %q", file, key)
+		}
+		v, err := s.fromTOML(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s: %s", file, key)
+		}
+		values[s.Env] = v
+	}
+	return values, nil
+}
+
+// flattenTree collects the values of the tables of tree under their dotted keys
+func flattenTree(prefix string, tree map[string]interface{}, flat map[string]interface{}) {
+	for key, value := range tree {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if table, ok := value.(map[string]interface{}); ok {
+			flattenTree(key, table, flat)
+			continue
+		}
+		flat[key] = value
+	}
+}
+
+// fromTOML converts a value of the config file into the syntax of the variable of the setting
+func (s Setting) fromTOML(value interface{}) (string, error) {
+	switch s.kind {
+	case kindBool:
+		if b, ok := value.(bool); ok {
+			return toggle(b), nil
+		}
+		return "", errors.Errorf("expected a boolean, got %v", value)
+	case kindInt:
+		switch v := value.(type) {
+		case int64:
+			return strconv.FormatInt(v, 10), nil
+		case string:
+			return v, nil
+		}
+		return "", errors.Errorf("expected an integer, got %v", value)
+	case kindList:
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case []interface{}:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				str, ok := item.(string)
+				if !ok {
+					return "", errors.Errorf("expected a list of strings, got %v", value)
+				}
+				items = append(items, str)
+			}
+			return strings.Join(items, ","), nil
+		}
+		return "", errors.Errorf("expected a list of strings, got %v", value)
+	default:
+		if str, ok := value.(string); ok {
+			return str, nil
+		}
+		return "", errors.Errorf("expected a string, got %v", value)
+	}
+}
+
+// ParseToggle parses on/off, or any boolean strconv.ParseBool accepts.
+// Other values are an error, the runner used to read them as off, which silently disabled a toggle set to e.g. yes.
+func ParseToggle(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, errors.Errorf("invalid toggle %q, expected on or off", s)
+	}
+	return b, nil
+}
+
+// toggle renders b as on/off
+func toggle(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+// toggleFromEnv parses the named on/off variable into b, leaves b untouched if the variable is not set
+func toggleFromEnv(vars variables, name string, b *bool) error {
+	value := vars.Get(name)
+	if value == "" {
+		return nil
+	}
+	parsed, err := ParseToggle(value)
+	if err != nil {
+		return errors.Wrap(err, name)
+	}
+	*b = parsed
+	return nil
+}
+
+// intFromEnv parses the named variable into n, leaves n untouched if the variable is not set
+func intFromEnv(vars variables, name string, n *int) error {
+	value := vars.Get(name)
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return errors.Wrapf(err, "could not parse %s: %s", name, value)
+	}
+	*n = parsed
+	return nil
+}
+
+// durationFromEnv parses the named variable into d, leaves d untouched if the variable is not set
+func durationFromEnv(vars variables, name string, d *time.Duration) error {
+	value := vars.Get(name)
+	if value == "" {
+		return nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return errors.Wrapf(err, "could not parse %s: %s", name, value)
+	}
+	*d = parsed
+	return nil
+}
+
+// formatMirrors renders mirrors in the syntax of ParseMirrors
+func formatMirrors(mirrors []Mirror) string {
+	specs := make([]string, 0, len(mirrors))
+	for _, m := range mirrors {
+		spec := m.URL
+		if m.Timeout != 0 && m.Timeout != DefaultMirrorTimeout {
+			spec += " " + m.Timeout.String()
+		}
+		specs = append(specs, spec)
+	}
+	return strings.Join(specs, ",")
+}
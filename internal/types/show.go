@@ -0,0 +1,114 @@
+package types
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// redacted replaces the secrets of a url
+const redacted = "xxxxx"
+
+// ShowConfig writes the effective config as a runner.toml, each setting that is not a default is annotated
+// with where it was read from. Credentials of mirror urls are redacted.
+func ShowConfig(w io.Writer, cfg *Config) error {
+	lines := []string{"# effective pocket-runner config, secrets are redacted"}
+	section := ""
+	for _, s := range Settings {
+		if s.value == nil {
+			continue
+		}
+		table, key := "", s.Key
+		if i := strings.LastIndex(s.Key, "."); i >= 0 {
+			table, key = s.Key[:i], s.Key[i+1:]
+		}
+		if table != section {
+			lines = append(lines, "", "["+table+"]")
+			section = table
+		}
+		line := key + " = " + s.formatTOML(s.value(cfg))
+		if s.noFile {
+			// home and config locate the file, they are shown for reference
+			line = "# " + line
+		}
+		switch source := cfg.Source(s.Env); source {
+		case SourceEnv:
+			line += " # env " + s.Env
+		case SourceFlag:
+			line += " # flag -" + s.Key
+		case SourceFile:
+			line += " # file " + cfg.File
+		}
+		lines = append(lines, line)
+	}
+	_, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+	return err
+}
+
+// formatTOML renders a value in the syntax of the variable of the setting as a TOML value
+func (s Setting) formatTOML(value string) string {
+	switch s.kind {
+	case kindBool:
+		if b, err := ParseToggle(value); err == nil {
+			return strconv.FormatBool(b)
+		}
+	case kindInt:
+		if _, err := strconv.Atoi(value); err == nil {
+			return value
+		}
+	case kindList:
+		items := []string{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			if s.secret {
+				fields := strings.Fields(item)
+				fields[0] = RedactURL(fields[0])
+				item = strings.Join(fields, " ")
+			}
+			items = append(items, strconv.Quote(item))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	}
+	return strconv.Quote(value)
+}
+
+// RedactURL masks the credentials of the userinfo and the query values of a url.
+// It works on the string, so the {placeholders} of url templates are kept as they are.
+func RedactURL(s string) string {
+	prefix, rest := "", s
+	if i := strings.Index(rest, "://"); i >= 0 {
+		prefix, rest = rest[:i+3], rest[i+3:]
+	}
+	authority, path := rest, ""
+	if i := strings.IndexAny(rest, "/?#"); i >= 0 {
+		authority, path = rest[:i], rest[i:]
+	}
+	if at := strings.LastIndex(authority, "@"); at >= 0 {
+		userinfo := authority[:at]
+		if i := strings.Index(userinfo, ":"); i >= 0 {
+			// keep the user, mask the password
+			userinfo = userinfo[:i+1] + redacted
+		} else {
+			// a user alone is usually a token
+			userinfo = redacted
+		}
+		authority = userinfo + authority[at:]
+	}
+	fragment := ""
+	if i := strings.Index(path, "#"); i >= 0 {
+		path, fragment = path[:i], path[i:]
+	}
+	if i := strings.Index(path, "?"); i >= 0 {
+		params := strings.Split(path[i+1:], "&")
+		for j, param := range params {
+			if eq := strings.Index(param, "="); eq >= 0 {
+				params[j] = param[:eq+1] + redacted
+			}
+		}
+		path = path[:i+1] + strings.Join(params, "&")
+	}
+	return prefix + authority + path + fragment
+}
@@ -0,0 +1,76 @@
+package types
+
+import (
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultShutdownSignal = syscall.SIGTERM
+	defaultShutdownGrace  = 30 * time.Second
+)
+
+// ShutdownPolicy describes how pocket-core is stopped before an upgrade or when the runner shuts down.
+// Signal is sent first, pocket-core is killed if it is still running once Grace has elapsed.
+type ShutdownPolicy struct {
+	// Signal asks pocket-core to stop, either SIGTERM or SIGINT
+	Signal syscall.Signal
+	// Grace is how long pocket-core has to exit before it is killed
+	Grace time.Duration
+}
+
+// DefaultShutdownPolicy returns the policy used when nothing is configured
+func DefaultShutdownPolicy() ShutdownPolicy {
+	return ShutdownPolicy{
+		Signal: defaultShutdownSignal,
+		Grace:  defaultShutdownGrace,
+	}
+}
+
+// WithDefaults returns a copy of the policy where every unset field has its default value
+func (p ShutdownPolicy) WithDefaults() ShutdownPolicy {
+	def := DefaultShutdownPolicy()
+	if p.Signal == 0 {
+		p.Signal = def.Signal
+	}
+	if p.Grace == 0 {
+		p.Grace = def.Grace
+	}
+	return p
+}
+
+// ParseShutdownSignal converts SIGTERM or SIGINT, with or without the SIG prefix, into a signal
+func ParseShutdownSignal(s string) (syscall.Signal, error) {
+	switch strings.TrimPrefix(strings.ToUpper(s), "SIG") {
+	case "TERM":
+		return syscall.SIGTERM, nil
+	case "INT":
+		return syscall.SIGINT, nil
+	}
+	return 0, errors.Errorf("unknown shutdown signal %q, expected SIGTERM or SIGINT", s)
+}
+
+// signalName is the name of a shutdown signal ParseShutdownSignal accepts
+func signalName(sig syscall.Signal) string {
+	switch sig {
+	case syscall.SIGTERM:
+		return "SIGTERM"
+	case syscall.SIGINT:
+		return "SIGINT"
+	}
+	return sig.String()
+}
+
+// Validate returns an error if this policy is invalid
+func (p ShutdownPolicy) Validate() error {
+	if p.Signal != 0 && p.Signal != syscall.SIGTERM && p.Signal != syscall.SIGINT {
+		return errors.Errorf("shutdown signal must be SIGTERM or SIGINT, got %s", p.Signal)
+	}
+	if p.Grace < 0 {
+		return errors.New("shutdown grace period must not be negative")
+	}
+	return nil
+}
@@ -1,27 +1,125 @@
 package types
 
 import (
+	"bytes"
+	"encoding/json"
 	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
 )
 
+// upgradeActionPrefix starts the upgrade.action attribute emitted by the gov module:
+// "UPGRADE CONFIRMED: <version> at height <height>"
+const (
+	upgradeActionPrefix = "UPGRADE CONFIRMED:"
+	upgradeActionHeight = "at height"
+)
+
+// ErrMalformedUpgrade is returned when an upgrade.action event cannot be decoded
+var ErrMalformedUpgrade = errors.New("malformed upgrade event")
+
 // UpgradeInfo is the details from the regexp
 type UpgradeInfo struct {
 	Name    string
 	Height  int64
 	Version string
+	// TxHash is the hash of the governance tx that scheduled the upgrade, if it was seen
+	TxHash string
+	// Checksum is the SHA-256 of the upgrade artifact, when the plan provides it
+	Checksum string
 }
 
+// SetUpgrade decodes the upgrade.action attribute s into ui, ui is left untouched if s is malformed
 func (ui *UpgradeInfo) SetUpgrade(s string) error {
-	tx := strings.Split(s, " ")
-	height, err := strconv.Atoi(strings.TrimSuffix(tx[5], "]"))
+	upgrade, err := ParseUpgradeAction(s)
+	if err != nil {
+		return err
+	}
+	ui.Name = upgrade.Name
+	ui.Version = upgrade.Version
+	ui.Height = upgrade.Height
+	return nil
+}
+
+// ParseUpgradeAction decodes the upgrade.action attribute of a gov upgrade tx.
+// Both the text emitted by the gov module and the (amino) json encoding of a gov Upgrade are accepted.
+func ParseUpgradeAction(s string) (*UpgradeInfo, error) {
+	s = strings.TrimSpace(s)
+	// values joined from the event map may still carry the brackets of the attribute list
+	s = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(s, "["), "]"))
+	if s == "" {
+		return nil, errors.Wrap(ErrMalformedUpgrade, "empty upgrade action")
+	}
+	var (
+		version, height string
+		err             error
+	)
+	if strings.HasPrefix(s, "{") {
+		version, height, err = parseUpgradeJSON(s)
+	} else {
+		version, height, err = parseUpgradeText(s)
+	}
 	if err != nil {
-		errors.Wrapf(err, "could not convert string: %s to integer", tx[5])
+		return nil, err
+	}
+	if err := ValidateUpgradeName(version); err != nil {
+		return nil, errors.Wrapf(err, "in upgrade action %q", s)
+	}
+	h, err := strconv.ParseInt(height, 10, 64)
+	if err != nil {
+		return nil, errors.Wrapf(ErrMalformedUpgrade, "invalid height %q in upgrade action %q", height, s)
+	}
+	if h <= 0 {
+		return nil, errors.Wrapf(ErrMalformedUpgrade, "height must be positive, got %d in upgrade action %q", h, s)
+	}
+	return &UpgradeInfo{Name: version, Version: version, Height: h}, nil
+}
+
+// parseUpgradeText splits "UPGRADE CONFIRMED: <version> at height <height>" into its version and height
+func parseUpgradeText(s string) (version, height string, err error) {
+	if !strings.HasPrefix(s, upgradeActionPrefix) {
+		return "", "", errors.Wrapf(ErrMalformedUpgrade, "expected the %q prefix in upgrade action %q", upgradeActionPrefix, s)
+	}
+	rest := strings.TrimPrefix(s, upgradeActionPrefix)
+	i := strings.LastIndex(rest, upgradeActionHeight)
+	if i < 0 {
+		return "", "", errors.Wrapf(ErrMalformedUpgrade, "missing %q in upgrade action %q", upgradeActionHeight, s)
+	}
+	version = strings.TrimSpace(rest[:i])
+	height = strings.TrimSpace(rest[i+len(upgradeActionHeight):])
+	return version, height, nil
+}
+
+// parseUpgradeJSON decodes a gov Upgrade, either bare or wrapped in an amino {"type", "value"} envelope.
+// Amino encodes int64 as a string so the height is accepted in both forms.
+func parseUpgradeJSON(s string) (version, height string, err error) {
+	var upgrade struct {
+		Height  json.RawMessage `json:"Height"`
+		Version string          `json:"Version"`
+		Value   json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal([]byte(s), &upgrade); err != nil {
+		return "", "", errors.Wrapf(ErrMalformedUpgrade, "invalid json in upgrade action %q: %v", s, err)
+	}
+	if len(upgrade.Value) > 0 && upgrade.Version == "" && upgrade.Height == nil {
+		return parseUpgradeJSON(string(upgrade.Value))
+	}
+	if upgrade.Height == nil {
+		return "", "", errors.Wrapf(ErrMalformedUpgrade, "missing height in upgrade action %q", s)
+	}
+	return upgrade.Version, string(bytes.Trim(upgrade.Height, `"`)), nil
+}
+
+// ValidateUpgradeName rejects versions that cannot name an upgrade directory
+func ValidateUpgradeName(version string) error {
+	switch {
+	case version == "":
+		return errors.Wrap(ErrMalformedUpgrade, "missing version")
+	case version == "." || version == "..":
+		return errors.Wrapf(ErrMalformedUpgrade, "invalid version %q", version)
+	case strings.ContainsAny(version, " \t\r\n/\\"):
+		return errors.Wrapf(ErrMalformedUpgrade, "version %q contains whitespace or path separators", version)
 	}
-	ui.Name = tx[2]
-	ui.Height = int64(height)
-	ui.Version = tx[2]
 	return nil
 }
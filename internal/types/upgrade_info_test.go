@@ -0,0 +1,89 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestParseUpgradeAction(t *testing.T) {
+	cases := map[string]struct {
+		action        string
+		expectVersion string
+		expectHeight  int64
+		expectErr     bool
+	}{
+		"gov event": {
+			action:        "UPGRADE CONFIRMED: RC-0.2.0 at height 100",
+			expectVersion: "RC-0.2.0",
+			expectHeight:  100,
+		},
+		"joined attribute list": {
+			action:        "[UPGRADE CONFIRMED: 2.0.0 at height 10000]",
+			expectVersion: "2.0.0",
+			expectHeight:  10000,
+		},
+		"trailing bracket": {
+			action:        "UPGRADE CONFIRMED: 2.0.0 at height 10000]",
+			expectVersion: "2.0.0",
+			expectHeight:  10000,
+		},
+		"amino json": {
+			action:        `{"Height":"42","Version":"RC-0.3.0"}`,
+			expectVersion: "RC-0.3.0",
+			expectHeight:  42,
+		},
+		"amino envelope": {
+			action:        `{"type":"gov/upgrade","value":{"Height":"42","Version":"RC-0.3.0"}}`,
+			expectVersion: "RC-0.3.0",
+			expectHeight:  42,
+		},
+		"plain json": {
+			action:        `{"Height":7,"Version":"1.0.1"}`,
+			expectVersion: "1.0.1",
+			expectHeight:  7,
+		},
+		"empty":              {action: "", expectErr: true},
+		"short":              {action: "UPGRADE", expectErr: true},
+		"other action":       {action: "modified: gov/acl to: foo", expectErr: true},
+		"missing height":     {action: "UPGRADE CONFIRMED: 2.0.0", expectErr: true},
+		"missing version":    {action: "UPGRADE CONFIRMED: at height 10", expectErr: true},
+		"bad height":         {action: "UPGRADE CONFIRMED: 2.0.0 at height ten", expectErr: true},
+		"negative height":    {action: "UPGRADE CONFIRMED: 2.0.0 at height -1", expectErr: true},
+		"zero height":        {action: "UPGRADE CONFIRMED: 2.0.0 at height 0", expectErr: true},
+		"height overflow":    {action: "UPGRADE CONFIRMED: 2.0.0 at height 99999999999999999999", expectErr: true},
+		"path in version":    {action: "UPGRADE CONFIRMED: ../bin at height 10", expectErr: true},
+		"spaces in version":  {action: "UPGRADE CONFIRMED: two words at height 10", expectErr: true},
+		"invalid json":       {action: `{"Height":`, expectErr: true},
+		"json without field": {action: `{"Version":"1.0.0"}`, expectErr: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			upgrade, err := ParseUpgradeAction(tc.action)
+			if tc.expectErr {
+				if errors.Cause(err) != ErrMalformedUpgrade {
+					t.Errorf("expected ErrMalformedUpgrade, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+			if upgrade.Name != tc.expectVersion || upgrade.Version != tc.expectVersion || upgrade.Height != tc.expectHeight {
+				t.Errorf("unexpected upgrade %+v", upgrade)
+			}
+		})
+	}
+}
+
+func TestSetUpgradeMalformed(t *testing.T) {
+	upgrade := UpgradeInfo{Name: "keep", Version: "keep", Height: 1}
+	if err := upgrade.SetUpgrade("UPGRADE CONFIRMED:"); err == nil {
+		t.Error("expected an error for a truncated upgrade action")
+	}
+	if upgrade.Name != "keep" || upgrade.Height != 1 {
+		t.Errorf("malformed action modified the upgrade: %+v", upgrade)
+	}
+}
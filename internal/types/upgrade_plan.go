@@ -0,0 +1,78 @@
+package types
+
+import (
+	"sort"
+	"sync"
+)
+
+// UpgradePlan queues the upgrades the runner has to apply, keyed by height.
+// Governance keeps a single upgrade and may change its mind before the upgrade height is reached,
+// so scheduling an upgrade replaces every queued upgrade, as the upgrade tx overwrites the previous one on chain.
+type UpgradePlan struct {
+	upgrades map[int64]*UpgradeInfo
+	mu       sync.Mutex
+}
+
+// NewUpgradePlan returns a plan holding upgrades
+func NewUpgradePlan(upgrades ...*UpgradeInfo) *UpgradePlan {
+	plan := &UpgradePlan{upgrades: make(map[int64]*UpgradeInfo)}
+	for _, upgrade := range upgrades {
+		plan.Schedule(upgrade)
+	}
+	return plan
+}
+
+// Schedule queues upgrade, returns the upgrades it replaced
+func (p *UpgradePlan) Schedule(upgrade *UpgradeInfo) (replaced []*UpgradeInfo) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for height, queued := range p.upgrades {
+		delete(p.upgrades, height)
+		if queued.Name != upgrade.Name || queued.Height != upgrade.Height {
+			replaced = append(replaced, queued)
+		}
+	}
+	p.upgrades[upgrade.Height] = upgrade
+	return replaced
+}
+
+// Next returns the upgrade with the lowest height, nil if the plan is empty
+func (p *UpgradePlan) Next() *UpgradeInfo {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var next *UpgradeInfo
+	for _, queued := range p.upgrades {
+		if next == nil || queued.Height < next.Height {
+			next = queued
+		}
+	}
+	return next
+}
+
+// Done removes upgrade once it was applied
+func (p *UpgradePlan) Done(upgrade *UpgradeInfo) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if queued, ok := p.upgrades[upgrade.Height]; ok && queued.Name == upgrade.Name {
+		delete(p.upgrades, upgrade.Height)
+	}
+}
+
+// Upgrades returns the queued upgrades ordered by height
+func (p *UpgradePlan) Upgrades() []*UpgradeInfo {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	upgrades := make([]*UpgradeInfo, 0, len(p.upgrades))
+	for _, queued := range p.upgrades {
+		upgrades = append(upgrades, queued)
+	}
+	sort.Slice(upgrades, func(i, j int) bool { return upgrades[i].Height < upgrades[j].Height })
+	return upgrades
+}
+
+// Len is the number of queued upgrades
+func (p *UpgradePlan) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.upgrades)
+}
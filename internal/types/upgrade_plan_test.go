@@ -0,0 +1,36 @@
+package types
+
+import "testing"
+
+func TestUpgradePlan(t *testing.T) {
+	first := &UpgradeInfo{Name: "2.0.0", Version: "2.0.0", Height: 20}
+	second := &UpgradeInfo{Name: "3.0.0", Version: "3.0.0", Height: 30}
+	plan := NewUpgradePlan(second, first)
+	if next := plan.Next(); next != first || plan.Len() != 1 {
+		t.Errorf("next upgrade = %+v, want %+v alone", next, first)
+	}
+
+	// governance keeps a single upgrade, an upgrade tx replaces every queued upgrade
+	replacement := &UpgradeInfo{Name: "2.0.1", Version: "2.0.1", Height: 25}
+	if replaced := plan.Schedule(replacement); len(replaced) != 1 || replaced[0] != first {
+		t.Errorf("expected %s to be replaced, got %+v", first.Name, replaced)
+	}
+	// governance delayed the upgrade
+	delayed := &UpgradeInfo{Name: "2.0.1", Version: "2.0.1", Height: 35}
+	if replaced := plan.Schedule(delayed); len(replaced) != 1 || replaced[0] != replacement {
+		t.Errorf("expected %s to be rescheduled, got %+v", replacement.Name, replaced)
+	}
+	// seeing the same upgrade again replaces nothing
+	if replaced := plan.Schedule(&UpgradeInfo{Name: "2.0.1", Version: "2.0.1", Height: 35, TxHash: "AB"}); len(replaced) != 0 {
+		t.Errorf("expected nothing to be replaced, got %+v", replaced)
+	}
+	upgrades := plan.Upgrades()
+	if len(upgrades) != 1 || upgrades[0].Name != "2.0.1" || upgrades[0].Height != 35 {
+		t.Errorf("unexpected plan %+v", upgrades)
+	}
+
+	plan.Done(upgrades[0])
+	if plan.Len() != 0 || plan.Next() != nil {
+		t.Errorf("expected an empty plan, got %+v", plan.Upgrades())
+	}
+}
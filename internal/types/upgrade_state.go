@@ -0,0 +1,240 @@
+package types
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const upgradeStateFile = "upgrade-state.json"
+
+// UpgradeRecord is an upgrade as persisted in the state file
+type UpgradeRecord struct {
+	Name        string     `json:"name"`
+	Version     string     `json:"version"`
+	Height      int64      `json:"height"`
+	TxHash      string     `json:"tx_hash,omitempty"`
+	Checksum    string     `json:"checksum,omitempty"`
+	ScheduledAt time.Time  `json:"scheduled_at"`
+	AppliedAt   *time.Time `json:"applied_at,omitempty"`
+	// Rollback is set when the runner switched binaries itself and may undo the upgrade
+	Rollback     *RollbackPoint `json:"rollback,omitempty"`
+	VerifiedAt   *time.Time     `json:"verified_at,omitempty"`
+	RolledBackAt *time.Time     `json:"rolled_back_at,omitempty"`
+	// Failure is why the upgrade was rolled back
+	Failure string `json:"failure,omitempty"`
+}
+
+// Info converts the record back into the upgrade the runner waits for
+func (r UpgradeRecord) Info() *UpgradeInfo {
+	return &UpgradeInfo{Name: r.Name, Version: r.Version, Height: r.Height, TxHash: r.TxHash, Checksum: r.Checksum}
+}
+
+// UpgradeState keeps track of the pending and applied upgrades so they survive runner restarts.
+// Every change is written to disk atomically.
+type UpgradeState struct {
+	Pending []UpgradeRecord `json:"pending,omitempty"`
+	Applied []UpgradeRecord `json:"applied,omitempty"`
+	path    string
+	mu      sync.Mutex
+}
+
+// UpgradeStateFile is the path to the file holding the upgrade state
+func (cfg *Config) UpgradeStateFile() string {
+	return filepath.Join(cfg.Root(), upgradeStateFile)
+}
+
+// LoadUpgradeState reads the upgrade state at path, an empty state is returned if the file does not exist yet
+func LoadUpgradeState(path string) (*UpgradeState, error) {
+	state := &UpgradeState{path: path}
+	bz, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read upgrade state %s", path)
+	}
+	if err := json.Unmarshal(bz, state); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse upgrade state %s", path)
+	}
+	return state, nil
+}
+
+// PendingUpgrades returns the upgrades the runner is waiting for ordered by height
+func (s *UpgradeState) PendingUpgrades() []*UpgradeInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	upgrades := make([]*UpgradeInfo, 0, len(s.Pending))
+	for _, record := range s.Pending {
+		upgrades = append(upgrades, record.Info())
+	}
+	return upgrades
+}
+
+// Schedule records info as the pending upgrade, replacing every pending upgrade like the upgrade tx does on chain
+func (s *UpgradeState) Schedule(info *UpgradeInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scheduled := UpgradeRecord{
+		Name:        info.Name,
+		Version:     info.Version,
+		Height:      info.Height,
+		TxHash:      info.TxHash,
+		Checksum:    info.Checksum,
+		ScheduledAt: time.Now().UTC(),
+	}
+	for _, record := range s.Pending {
+		if record.Name == info.Name && record.Height == info.Height {
+			if len(s.Pending) == 1 && (record.TxHash != "" || info.TxHash == "") && (record.Checksum != "" || info.Checksum == "") {
+				return nil // already scheduled, and there is nothing new to learn about it
+			}
+			// keep what was known about the upgrade
+			if scheduled.TxHash == "" {
+				scheduled.TxHash = record.TxHash
+			}
+			if scheduled.Checksum == "" {
+				scheduled.Checksum = record.Checksum
+			}
+		}
+	}
+	s.Pending = []UpgradeRecord{scheduled}
+	return s.save()
+}
+
+// Cancel drops the pending upgrade named name, it is a no-op if the upgrade is not pending
+func (s *UpgradeState) Cancel(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.takePending(name); !ok {
+		return nil
+	}
+	return s.save()
+}
+
+// MarkApplied moves the pending upgrade named after info to the applied upgrades
+func (s *UpgradeState) MarkApplied(info *UpgradeInfo) error {
+	return s.markApplied(info, nil)
+}
+
+// MarkSwitched records that the runner switched to the upgrade named after info, the upgrade stays
+// unverified until MarkVerified or MarkRolledBack is called
+func (s *UpgradeState) MarkSwitched(info *UpgradeInfo, point RollbackPoint) error {
+	return s.markApplied(info, &point)
+}
+
+// Unverified returns the last applied upgrade if it still has to prove healthy, nil otherwise
+func (s *UpgradeState) Unverified() *UpgradeRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.Applied) == 0 {
+		return nil
+	}
+	last := s.Applied[len(s.Applied)-1]
+	if last.Rollback == nil || last.VerifiedAt != nil || last.RolledBackAt != nil {
+		return nil
+	}
+	return &last
+}
+
+// MarkVerified records that the last applied upgrade named name is healthy
+func (s *UpgradeState) MarkVerified(name string) error {
+	return s.updateApplied(name, func(record *UpgradeRecord) {
+		now := time.Now().UTC()
+		record.VerifiedAt = &now
+	})
+}
+
+// MarkRolledBack records that the last applied upgrade named name was undone because of failure
+func (s *UpgradeState) MarkRolledBack(name string, failure error) error {
+	return s.updateApplied(name, func(record *UpgradeRecord) {
+		now := time.Now().UTC()
+		record.RolledBackAt = &now
+		record.Failure = failure.Error()
+	})
+}
+
+// RolledBack reports whether info was applied and rolled back before, such an upgrade is not applied again
+func (s *UpgradeState) RolledBack(info *UpgradeInfo) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, record := range s.Applied {
+		if record.Name == info.Name && record.Height == info.Height && record.RolledBackAt != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// updateApplied changes the last applied upgrade named name and saves the state
+func (s *UpgradeState) updateApplied(name string, update func(*UpgradeRecord)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.Applied) - 1; i >= 0; i-- {
+		if s.Applied[i].Name == name {
+			update(&s.Applied[i])
+			return s.save()
+		}
+	}
+	return errors.Errorf("upgrade %s was not applied", name)
+}
+
+// markApplied moves the pending upgrade named after info to the applied upgrades
+func (s *UpgradeState) markApplied(info *UpgradeInfo, point *RollbackPoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.takePending(info.Name)
+	if !ok {
+		record = UpgradeRecord{
+			Name:        info.Name,
+			Version:     info.Version,
+			Height:      info.Height,
+			TxHash:      info.TxHash,
+			ScheduledAt: time.Now().UTC(),
+		}
+	}
+	now := time.Now().UTC()
+	record.AppliedAt = &now
+	record.Rollback = point
+	s.Applied = append(s.Applied, record)
+	return s.save()
+}
+
+// takePending removes the pending upgrade named name and returns it
+func (s *UpgradeState) takePending(name string) (UpgradeRecord, bool) {
+	for i, record := range s.Pending {
+		if record.Name == name {
+			s.Pending = append(s.Pending[:i], s.Pending[i+1:]...)
+			return record, true
+		}
+	}
+	return UpgradeRecord{}, false
+}
+
+// save writes the state to a temporary file and atomically copies it over the state file
+func (s *UpgradeState) save() error {
+	bz, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "cannot encode upgrade state")
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), "."+upgradeStateFile)
+	if err != nil {
+		return errors.Wrap(err, "cannot create temporary upgrade state")
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(bz); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "cannot write temporary upgrade state")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "cannot write temporary upgrade state")
+	}
+	if err := Copy(tmp.Name(), s.path, Options{Atomic: true}); err != nil {
+		return errors.Wrapf(err, "cannot save upgrade state %s", s.path)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package types
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestUpgradeState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "upgrade-state")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, upgradeStateFile)
+
+	state, err := LoadUpgradeState(path)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(state.PendingUpgrades()) != 0 {
+		t.Error("a new state must not have a pending upgrade")
+		t.FailNow()
+	}
+	upgrade := &UpgradeInfo{Name: "RC-0.2.0", Version: "RC-0.2.0", Height: 10, TxHash: "ABCD"}
+	if err := state.Schedule(upgrade); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	// a restarted runner resumes the pending upgrade
+	state, err = LoadUpgradeState(path)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	pending := state.PendingUpgrades()
+	if len(pending) != 1 || *pending[0] != *upgrade {
+		t.Errorf("pending upgrades = %+v, want %+v", pending, upgrade)
+		t.FailNow()
+	}
+	if state.Pending[0].ScheduledAt.IsZero() {
+		t.Error("scheduled time was not recorded")
+	}
+
+	if err := state.MarkApplied(upgrade); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	state, err = LoadUpgradeState(path)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(state.Pending) != 0 || len(state.Applied) != 1 {
+		t.Errorf("unexpected state after applying: %+v", state)
+		t.FailNow()
+	}
+	if applied := state.Applied[0]; applied.TxHash != "ABCD" || applied.AppliedAt == nil {
+		t.Errorf("applied upgrade was not recorded properly: %+v", applied)
+	}
+	// no temporary files are left behind
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(files) != 1 {
+		t.Errorf("expected only the state file in %s, found %d files", dir, len(files))
+	}
+}
+
+func TestUpgradeStateQueue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "upgrade-state")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, upgradeStateFile)
+	state, err := LoadUpgradeState(path)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	// governance keeps a single upgrade, every upgrade tx overwrites the previous one
+	for _, upgrade := range []*UpgradeInfo{
+		{Name: "3.0.0", Version: "3.0.0", Height: 30},
+		{Name: "2.0.0", Version: "2.0.0", Height: 20},
+		{Name: "2.0.1", Version: "2.0.1", Height: 20},
+		{Name: "3.0.0", Version: "3.0.0", Height: 35, TxHash: "AB"},
+		{Name: "3.0.0", Version: "3.0.0", Height: 35}, // seen again on startup, without its tx
+	} {
+		if err := state.Schedule(upgrade); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+	}
+	state, err = LoadUpgradeState(path)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	pending := state.PendingUpgrades()
+	if len(pending) != 1 || pending[0].Name != "3.0.0" || pending[0].Height != 35 || pending[0].TxHash != "AB" {
+		t.Errorf("unexpected pending upgrades: %+v", state.Pending)
+	}
+	if err := state.Cancel("3.0.0"); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(state.PendingUpgrades()) != 0 {
+		t.Errorf("expected no pending upgrade, got %+v", state.Pending)
+	}
+}
+
+func TestUpgradeStateVerification(t *testing.T) {
+	dir, err := ioutil.TempDir("", "upgrade-state")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	state, err := LoadUpgradeState(filepath.Join(dir, upgradeStateFile))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	first := &UpgradeInfo{Name: "2.0.0", Version: "2.0.0", Height: 20}
+	if err := state.MarkSwitched(first, RollbackPoint{Backup: "/backups/2.0.0-20"}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if record := state.Unverified(); record == nil || record.Name != first.Name || record.Rollback.Previous != "" {
+		t.Errorf("expected %s to be unverified, got %+v", first.Name, record)
+	}
+	if err := state.MarkVerified(first.Name); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if record := state.Unverified(); record != nil {
+		t.Errorf("expected no unverified upgrade, got %+v", record)
+	}
+
+	second := &UpgradeInfo{Name: "3.0.0", Version: "3.0.0", Height: 30}
+	if err := state.MarkSwitched(second, RollbackPoint{Previous: first.Name}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err := state.MarkRolledBack(second.Name, errors.New("exited")); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if record := state.Unverified(); record != nil {
+		t.Errorf("a rolled back upgrade is not verified, got %+v", record)
+	}
+	if !state.RolledBack(second) || state.RolledBack(first) {
+		t.Errorf("only %s was rolled back: %+v", second.Name, state.Applied)
+	}
+	if state.Applied[1].Failure != "exited" {
+		t.Errorf("the failure was not recorded: %+v", state.Applied[1])
+	}
+	if err := state.MarkVerified("4.0.0"); err == nil {
+		t.Error("expected an error for an upgrade that was never applied")
+	}
+}
@@ -0,0 +1,67 @@
+package types
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// minisign public keys are "Ed" followed by an 8 byte key id and the ed25519 public key
+const (
+	minisignAlgorithm = "Ed"
+	minisignKeyIDSize = 8
+	minisignKeySize   = 2 + minisignKeyIDSize + ed25519.PublicKeySize
+)
+
+// VerifyPolicy describes how downloaded upgrade artifacts are verified before they are unpacked or executed
+type VerifyPolicy struct {
+	// SkipChecksum accepts artifacts without a SHA-256 checksum, it is unsafe
+	SkipChecksum bool
+	// PublicKeys are the minisign public keys trusted to sign releases.
+	// When set, every artifact must carry a detached signature made by one of them.
+	PublicKeys []string
+}
+
+// SigningKey is a minisign ed25519 public key
+type SigningKey struct {
+	ID  [minisignKeyIDSize]byte
+	Key ed25519.PublicKey
+}
+
+// ParseSigningKey decodes a minisign public key, either the base64 key alone or the content of a .pub file
+func ParseSigningKey(s string) (SigningKey, error) {
+	var key SigningKey
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	encoded := strings.TrimSpace(lines[len(lines)-1])
+	bz, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return key, errors.Wrapf(err, "invalid public key %q", encoded)
+	}
+	if len(bz) != minisignKeySize || string(bz[:2]) != minisignAlgorithm {
+		return key, errors.Errorf("public key %q is not a minisign ed25519 key", encoded)
+	}
+	copy(key.ID[:], bz[2:2+minisignKeyIDSize])
+	key.Key = ed25519.PublicKey(bz[2+minisignKeyIDSize:])
+	return key, nil
+}
+
+// SigningKeys decodes the public keys of the policy
+func (p VerifyPolicy) SigningKeys() ([]SigningKey, error) {
+	keys := make([]SigningKey, 0, len(p.PublicKeys))
+	for _, s := range p.PublicKeys {
+		key, err := ParseSigningKey(s)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Validate returns an error if this policy is invalid
+func (p VerifyPolicy) Validate() error {
+	_, err := p.SigningKeys()
+	return errors.Wrap(err, "DAEMON_SIGNING_KEYS")
+}
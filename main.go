@@ -1,10 +1,23 @@
 package main
 
 import (
+	"log"
 	"os"
 )
 
 func main() {
-	args := os.Args[1:]
-	Run(args)
+	settings, args, err := ParseSettings(os.Args[1:])
+	if err != nil {
+		log.Printf("%v\n", err)
+		os.Exit(2)
+	}
+	if len(args) > 0 {
+		switch args[0] {
+		case "prepare-upgrade":
+			os.Exit(PrepareUpgrade(settings, args[1:]))
+		case "config":
+			os.Exit(ConfigCommand(settings, args[1:]))
+		}
+	}
+	os.Exit(Run(settings, args))
 }
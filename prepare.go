@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"github.com/pokt-network/pocket-runner/x/runner"
+)
+
+const prepareUsage = "usage: pocket-runner prepare-upgrade <name> [-from <binary>] [-checksum <sha256>] [-force]"
+
+// PrepareUpgrade implements `pocket-runner prepare-upgrade`, it stages the binary of an upgrade without starting pocket-core
+// and returns the exit status of the command. settings override runner.toml and the environment.
+func PrepareUpgrade(settings map[string]string, args []string) int {
+	flags := flag.NewFlagSet("prepare-upgrade", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), prepareUsage)
+		flags.PrintDefaults()
+	}
+	var opts runner.PrepareOptions
+	flags.StringVar(&opts.From, "from", "", "install this local binary instead of downloading the upgrade")
+	flags.StringVar(&opts.Checksum, "checksum", "", "expected SHA-256 of the downloaded artifact, or of the -from binary")
+	flags.BoolVar(&opts.Force, "force", false, "replace the binary already installed for the upgrade")
+	name, err := parsePrepareArgs(flags, args)
+	if err == flag.ErrHelp {
+		return 0
+	}
+	if err != nil {
+		log.Printf("%v\n%s\n", err, prepareUsage)
+		return 2
+	}
+
+	cfg, err := runner.LoadConfig(settings)
+	if err != nil {
+		log.Printf("%+v\n", err)
+		return 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(signals)
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	version, err := runner.PrepareUpgrade(ctx, cfg, name, opts)
+	if err != nil {
+		log.Printf("%+v\n", err)
+		return 1
+	}
+	fmt.Printf("upgrade %s is ready at %s\n%s\n", name, cfg.UpgradeBin(name), version)
+	return 0
+}
+
+// parsePrepareArgs returns the upgrade name of the arguments, flags are accepted before and after it
+func parsePrepareArgs(flags *flag.FlagSet, args []string) (string, error) {
+	if err := flags.Parse(args); err != nil {
+		return "", err
+	}
+	if flags.NArg() == 0 {
+		return "", errors.New("missing the upgrade name")
+	}
+	name := flags.Arg(0)
+	if err := flags.Parse(flags.Args()[1:]); err != nil {
+		return "", err
+	}
+	if flags.NArg() != 0 {
+		return "", errors.Errorf("unexpected arguments %v", flags.Args())
+	}
+	return name, nil
+}
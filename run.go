@@ -4,38 +4,32 @@ import (
 	"context"
 	"log"
 	"os"
-	"os/exec"
 	"os/signal"
-	"strings"
 	"syscall"
-	"time"
 
-	"github.com/pokt-network/pocket-runner/internal/types"
+	"github.com/pkg/errors"
 	"github.com/pokt-network/pocket-runner/x/runner"
-	tmTypes "github.com/tendermint/tendermint/types"
 )
 
-func Run(args []string) {
-	cfg, err := types.GetConfigFromEnv()
+// Run runs pocket-core with args until the runner is signalled or cannot go on, returns the status to exit with.
+// settings override runner.toml and the environment.
+func Run(settings map[string]string, args []string) int {
+	cfg, err := runner.LoadConfig(settings)
 	if err != nil {
 		log.Printf("%+v\n", err)
-		os.Exit(1)
+		return 1
 	}
-	// Initial launcher, separated from loop due to passphrase
-	cmd, err := runner.LaunchProcess(cfg, args, os.Stdout, os.Stderr, os.Stdin)
+	r, err := runner.NewRunner(cfg, args, os.Stdout, os.Stderr)
 	if err != nil {
 		log.Printf("%+v\n", err)
-		os.Exit(1)
+		return 1
 	}
-	time.Sleep(time.Second * 10)
-
-	errors := make(chan error)
-	upgrades := make(chan *types.UpgradeInfo)
-	commands := make(chan *exec.Cmd)
-	var tmListener = runner.NewEventListener(cfg)
 	ctx, cancel := context.WithCancel(context.Background())
-	log.Println("starting listeners")
-
+	defer cancel()
+	if err := r.Start(ctx); err != nil {
+		log.Printf("%+v\n", err)
+		return 1
+	}
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals,
 		syscall.SIGTERM,
@@ -43,116 +37,28 @@ func Run(args []string) {
 		syscall.SIGQUIT,
 		os.Kill,
 		os.Interrupt)
-
-	fanJobs := func(ctx context.Context, cfg *types.Config, args []string, cmd *exec.Cmd, listener *runner.EventListener, upgrades chan *types.UpgradeInfo, commands chan *exec.Cmd, errors chan error) {
-		go WaitForUpgrade(ctx, cfg, tmListener, upgrades, errors)
-		go WaitForBlockHeight(ctx, cfg, args, cmd, tmListener, upgrades, commands, errors)
-	}
-
 	go func() {
-		fanJobs(ctx, cfg, args, cmd, tmListener, upgrades, commands, errors)
-		for {
-			select {
-			case currentCommand := <-commands:
-				cmd, err = runner.LaunchProcess(cfg, args, os.Stdout, os.Stderr, os.Stdin)
-				if err != nil {
-					errors <- err
-				}
-				cancel()
-				time.Sleep(time.Second * 5)
-				ctx, cancel = context.WithCancel(context.Background())
-				tmListener = tmListener.Reset(cfg)
-				fanJobs(ctx, cfg, args, currentCommand, tmListener, upgrades, commands, errors)
-			}
-		}
+		<-signals
+		cancel()
 	}()
-
-	log.Println("Loop is begining!")
-	for {
-		select {
-		case err := <-errors:
-			log.Printf("%+v\n", err)
-			os.Exit(1)
-		case <-signals:
-			cancel()
-			tmListener.Stop()
-			if err := cmd.Process.Kill(); err != nil {
-				log.Printf("%+v\n", err)
-				os.Exit(1)
-			}
-			os.Exit(0)
-		}
-	}
+	return ExitStatus(r.Wait())
 }
 
-// WaitForBlockHeight listens for upgrades, per upgrade checks the current block header & upgrades if neccesary.
-func WaitForBlockHeight(ctx context.Context, cfg *types.Config, args []string, cmd *exec.Cmd, listener *runner.EventListener, upgrades chan *types.UpgradeInfo, commands chan *exec.Cmd, errors chan error) {
-	log.Printf("\n *****Listen For BlockHeight***** \n")
-	var err error
-	var currentUpgrade *types.UpgradeInfo
-
-	for {
-		select {
-		case rawHeaderEvt := <-listener.HeaderChan:
-			if currentUpgrade == nil {
-				// wait for upgrade if no current upgrade this way the blockHeight won't change
-				currentUpgrade = <-upgrades
-			}
-			upgrade := currentUpgrade
-			headerEvt := rawHeaderEvt.Data.(tmTypes.EventDataNewBlockHeader)
-			log.Printf("\n *****Received Block Header for Height %v ***** \n", headerEvt.Header.Height)
-			if upgrade.Height != headerEvt.Header.Height {
-				continue
-			}
-			if err := cmd.Process.Kill(); err != nil { // PROCESS MUST DIE BEFORE UPGRADING; cfg.Current is a symlink otherwise bugs might happen
-				errors <- err
-			}
-			if err := runner.Upgrade(cfg, upgrade); err != nil {
-				errors <- err
-			}
-			log.Printf("Upgrade to %s performed successfully!!\n", upgrade.Name)
-			cmd, err = runner.LaunchProcess(cfg, args, os.Stdout, os.Stderr, os.Stdin)
-			if err != nil {
-				errors <- err
-			}
-			commands <- cmd
-		case <-ctx.Done():
-			return
-		}
+// ExitStatus maps why the runner stopped to the status of the pocket-runner process
+func ExitStatus(err error) int {
+	if err == nil {
+		return 0
 	}
-}
-
-// WaitForUpgrade listens transactions and filters upgrades, passess them to the upgrade channel
-func WaitForUpgrade(ctx context.Context, cfg *types.Config, listener *runner.EventListener, upgrades chan *types.UpgradeInfo, errors chan error) {
-	log.Printf("\n *****Wait for Upgrade***** \n")
-	for {
-		upgrade := &types.UpgradeInfo{}
-		select {
-		case rawTxEvt := <-listener.TxChan:
-			log.Printf("\n *****Received a Tx***** \n")
-			if len(rawTxEvt.Events["upgrade.action"]) == 1 {
-				log.Printf("\n *****Received an Upgrade***** \n")
-				if err := upgrade.SetUpgrade(strings.Join(rawTxEvt.Events["upgrade.action"], "")); err != nil {
-					errors <- err
-				}
-
-				if err := types.CheckBinary(cfg.UpgradeBin(upgrade.Name)); err != nil {
-					if cfg.AllowDownload {
-						if er := runner.DownloadBinary(cfg, upgrade); er != nil {
-							errors <- er
-						} else {
-							upgrades <- upgrade
-							continue
-						}
-					} else {
-						errors <- err
-					}
-				}
-				log.Printf("\n *****Sent an Upgrade***** \n")
-				upgrades <- upgrade
-			}
-		case <-ctx.Done():
-			return // singal to kill process was sent terminate exectuion
-		}
+	if exited, ok := err.(*runner.ExitError); ok {
+		// mirror pocket-core, whoever supervises the runner sees how it exited
+		log.Printf("not relaunching pocket-core: %v\n", exited.Err)
+		return exited.Exit.Status()
+	}
+	if errors.Cause(err) == runner.ErrRestartDisabled {
+		// the upgrade was applied, whoever supervises the runner is in charge of starting it again
+		log.Printf("upgrade applied, not relaunching pocket-core: %v\n", err)
+		return 0
 	}
+	log.Printf("%+v\n", err)
+	return 1
 }
@@ -4,8 +4,8 @@ import (
 	"bytes"
 	"context"
 	"os"
-	"os/exec"
 	"sync"
+	"syscall"
 	"testing"
 
 	"github.com/pkg/errors"
@@ -24,6 +24,7 @@ func TestRun(t *testing.T) {
 		t.FailNow()
 	}
 	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	home, err := copyTestData("validate")
 	if err != nil {
 		t.Error(err)
@@ -32,39 +33,24 @@ func TestRun(t *testing.T) {
 	cfg := &types.Config{Home: home, Name: "test-runnerd", Port: "36657"}
 	defer os.RemoveAll(home)
 	const version = "RC-0.2.0"
-	var stdout, stderr, stdin bytes.Buffer
+	var stdout, stderr bytes.Buffer
 
 	args := []string{"start", "--blockTime", "1"} // NOTE add short block times for testing purposes
-	cmd, err := runner.LaunchProcess(cfg, args, &stdout, &stderr, &stdin)
+	r, err := runner.NewRunner(cfg, args, &stdout, &stderr)
 	if err != nil {
 		t.Error(err)
 		t.FailNow()
 	}
-
-	upgrades := make(chan *types.UpgradeInfo)
-	commands := make(chan *exec.Cmd)
-	errs := make(chan error)
+	if err := r.Start(ctx); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err := r.Start(ctx); err != runner.ErrAlreadyStarted {
+		t.Errorf("expected ErrAlreadyStarted, got %v", err)
+	}
 
 	memCli, stopCli, evtChan := subscribeTo(t, tmTypes.EventNewBlock)
 
-	listener := runner.NewEventListener(cfg)
-	go WaitForUpgrade(ctx, cfg, listener, upgrades, errs)
-	go WaitForBlockHeight(ctx, cfg, args, cmd, listener, upgrades, commands, errs)
-
-	// intercept any errors from Upgrades
-	go func() {
-		for {
-			select {
-			case err := <-errs:
-				t.Error(err)
-				t.FailNow()
-			case <-ctx.Done():
-				t.Log("error routine ending")
-				return
-			}
-		}
-	}()
-
 	select {
 	case <-evtChan:
 		tx, err := gov.UpgradeTx(memCodec(), memCli, kb, cb.GetAddress(), govTypes.Upgrade{
@@ -83,109 +69,60 @@ func TestRun(t *testing.T) {
 	wg := &sync.WaitGroup{}
 	wg.Add(1)
 	go func(wg *sync.WaitGroup) {
-		for {
-			select {
-			case <-commands: // NOTE an upgrade was completed
-				upgradeBin := cfg.UpgradeBin("RC-0.2.0")
-				currentBin, err := cfg.CurrentBin()
-				t.Log(upgradeBin)
-				t.Log(currentBin)
-				if err != nil {
-					t.Error(err)
-					t.FailNow()
-				}
-				if upgradeBin != currentBin {
-					t.Errorf("upgrade bin: %s does not match current bin: %s", upgradeBin, currentBin)
-				}
-				wg.Done()
-			case <-ctx.Done():
-				t.Log("test assertion was completed")
+		defer wg.Done()
+		for evt := range r.Events() {
+			if evt.Kind != runner.EventStage || evt.Transition.To != runner.StageVerifying {
+				continue
+			}
+			// NOTE an upgrade was completed, pocket-core was relaunched and is in its health window
+			upgradeBin := cfg.UpgradeBin("RC-0.2.0")
+			currentBin, err := cfg.CurrentBin()
+			t.Log(upgradeBin)
+			t.Log(currentBin)
+			if err != nil {
+				t.Error(err)
 				return
 			}
+			if upgradeBin != currentBin {
+				t.Errorf("upgrade bin: %s does not match current bin: %s", upgradeBin, currentBin)
+			}
+			return
 		}
+		// the runner stopped before the upgrade
+		t.Errorf("the runner stopped: %v", r.Wait())
 	}(wg)
 	wg.Wait()
-	cancel()
-	listener.Stop()
-	stopCli()
-	cleanup()
-	t.Log("test should have ended")
-	return
-}
-func TestWaitForUpgrade(t *testing.T) {
-	t.Log("test is beggining")
-	_, kb, cleanup := NewInMemoryTendermintNode(t, oneValTwoNodeGenesisState())
-	cb, err := kb.GetCoinbase()
+	saved, err := types.LoadUpgradeState(cfg.UpgradeStateFile())
 	if err != nil {
 		t.Error(err)
-		t.FailNow()
+	} else if len(saved.Pending) != 0 || len(saved.Applied) != 1 || saved.Applied[0].Name != version {
+		t.Errorf("upgrade %s was not recorded as applied: %+v", version, saved)
 	}
-	ctx, cancel := context.WithCancel(context.Background())
-	home, err := copyTestData("validate")
-	if err != nil {
+	if err := r.Stop(); err != nil {
 		t.Error(err)
-		t.FailNow()
-	}
-	cfg := &types.Config{Home: home, Name: "test-runnerd", Port: "36657"}
-	defer os.RemoveAll(home)
-	const version = "RC-0.2.0"
-
-	upgrades := make(chan *types.UpgradeInfo)
-	errs := make(chan error)
-
-	memCli, stopCli, evtChan := subscribeTo(t, tmTypes.EventNewBlock)
-
-	listener := runner.NewEventListener(cfg)
-	go WaitForUpgrade(ctx, cfg, listener, upgrades, errs)
-	go func() {
-		for {
-			select {
-			case err := <-errs:
-				t.Error(err)
-				t.FailNow()
-			case <-ctx.Done():
-				t.Log("Error routine is over")
-				return
-			}
-		}
-	}() // intercept any errors from Upgrades
-
-	select {
-	case <-evtChan:
-		memCli, stopCli, evtChan = subscribeTo(t, tmTypes.EventNewBlockHeader)
-		tx, err := gov.UpgradeTx(memCodec(), memCli, kb, cb.GetAddress(), govTypes.Upgrade{
-			Height:  2,
-			Version: version,
-		}, "test")
-		if tx == nil {
-			t.Error(errors.New("tx is nil"))
-			t.FailNow()
-		}
-		if err != nil {
-			t.Error(err)
-			t.FailNow()
-		}
 	}
-	wg := &sync.WaitGroup{}
-	go func(wg *sync.WaitGroup) {
-		for {
-			select {
-			case upgrade := <-upgrades: // NOTE this means the tx was intercepted & sent as a valid upgrade for the runner
-				if upgrade.Name != version {
-					t.Errorf("received upgrade: %s does not match expected %s", upgrade.Name, version)
-				}
-				wg.Done()
-			case <-ctx.Done():
-				t.Log("test assertion was completed")
-				return
-			}
-		}
-	}(wg)
-	wg.Wait()
-	cancel()
 	stopCli()
-	listener.Stop()
 	cleanup()
-	t.Log("test ended")
+	t.Log("test should have ended")
 	return
 }
+
+func TestExitStatus(t *testing.T) {
+	cases := map[string]struct {
+		err      error
+		expected int
+	}{
+		"stopped":          {expected: 0},
+		"upgrade applied":  {err: errors.Wrap(runner.ErrRestartDisabled, "policy \"never\""), expected: 0},
+		"pocket-core exit": {err: &runner.ExitError{Exit: runner.ExitEvent{ExitCode: 3}, Err: runner.ErrRestartDisabled}, expected: 3},
+		"killed":           {err: &runner.ExitError{Exit: runner.ExitEvent{Signal: syscall.SIGKILL}, Err: runner.ErrRestartDisabled}, expected: 137},
+		"failure":          {err: runner.ErrBinaryMissing, expected: 1},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if status := ExitStatus(tc.err); status != tc.expected {
+				t.Errorf("expected status %d, got %d", tc.expected, status)
+			}
+		})
+	}
+}
@@ -0,0 +1,353 @@
+package runner
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/ulikunitz/xz"
+)
+
+// ArchiveFormat is a supported archive format
+type ArchiveFormat int
+
+const (
+	// FormatUnknown is not a supported archive
+	FormatUnknown ArchiveFormat = iota
+	// FormatZip is a zip archive
+	FormatZip
+	// FormatTarGz is a gzip compressed tarball
+	FormatTarGz
+	// FormatTarXz is a xz compressed tarball
+	FormatTarXz
+)
+
+func (f ArchiveFormat) String() string {
+	switch f {
+	case FormatZip:
+		return "zip"
+	case FormatTarGz:
+		return "tar.gz"
+	case FormatTarXz:
+		return "tar.xz"
+	}
+	return "unknown"
+}
+
+var (
+	// ErrUnsupportedArchive occurs when a file is neither a zip, a tar.gz nor a tar.xz
+	ErrUnsupportedArchive = errors.New("unsupported archive")
+	// ErrIllegalPath occurs when an archive entry, or the target of a link, would be written outside the destination
+	ErrIllegalPath = errors.New("illegal file path")
+	// errStopWalk is returned by a walkArchive callback to skip the remaining entries
+	errStopWalk = errors.New("stop walking the archive")
+)
+
+// archive magic bytes, a compressed tarball is recognized by its compression
+var (
+	zipMagic  = []byte("PK\x03\x04")
+	gzipMagic = []byte{0x1f, 0x8b}
+	xzMagic   = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+)
+
+// DetectArchiveFormat returns the format of archive from its extension, or from its magic bytes if the extension is not known
+func DetectArchiveFormat(archive string) (ArchiveFormat, error) {
+	if format := formatFromExtension(archive); format != FormatUnknown {
+		return format, nil
+	}
+	f, err := os.Open(archive)
+	if err != nil {
+		return FormatUnknown, err
+	}
+	defer f.Close()
+	magic := make([]byte, len(xzMagic))
+	n, err := io.ReadFull(f, magic)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return FormatUnknown, errors.Wrapf(ErrUnsupportedArchive, "%s: %v", filepath.Base(archive), err)
+	}
+	switch magic = magic[:n]; {
+	case bytes.HasPrefix(magic, zipMagic):
+		return FormatZip, nil
+	case bytes.HasPrefix(magic, gzipMagic):
+		return FormatTarGz, nil
+	case bytes.HasPrefix(magic, xzMagic):
+		return FormatTarXz, nil
+	}
+	return FormatUnknown, errors.Wrapf(ErrUnsupportedArchive, "%s, expected a zip, tar.gz or tar.xz", filepath.Base(archive))
+}
+
+// formatFromExtension returns the format named by the extension of file, FormatUnknown if there is none
+func formatFromExtension(file string) ArchiveFormat {
+	switch name := strings.ToLower(file); {
+	case strings.HasSuffix(name, ".zip"):
+		return FormatZip
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return FormatTarGz
+	case strings.HasSuffix(name, ".tar.xz"), strings.HasSuffix(name, ".txz"):
+		return FormatTarXz
+	}
+	return FormatUnknown
+}
+
+// archiveExtension returns the extension of the archive at link, ".zip" if it does not name a supported format
+func archiveExtension(link string) string {
+	switch formatFromExtension(link) {
+	case FormatTarGz:
+		return ".tar.gz"
+	case FormatTarXz:
+		return ".tar.xz"
+	}
+	return zipExtension
+}
+
+// archiveEntry is a file, directory or link of an archive
+type archiveEntry struct {
+	name string
+	mode os.FileMode
+	// link is the target of a symlink, or the archive path of the file a hardlink points to
+	link     string
+	hardlink bool
+	open     func() (io.ReadCloser, error)
+}
+
+// walkArchive calls fn for every entry of archive, in the order they are stored, until fn returns an error.
+// errStopWalk ends the walk without an error.
+func walkArchive(archive string, fn func(entry archiveEntry) error) error {
+	err := walkEntries(archive, fn)
+	if err == errStopWalk {
+		return nil
+	}
+	return err
+}
+
+func walkEntries(archive string, fn func(entry archiveEntry) error) error {
+	format, err := DetectArchiveFormat(archive)
+	if err != nil {
+		return err
+	}
+	if format == FormatZip {
+		return walkZip(archive, fn)
+	}
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var r io.Reader
+	switch format {
+	case FormatTarGz:
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	case FormatTarXz:
+		if r, err = xz.NewReader(bufio.NewReader(f)); err != nil {
+			return err
+		}
+	}
+	return walkTar(tar.NewReader(r), fn)
+}
+
+func walkZip(archive string, fn func(entry archiveEntry) error) error {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		entry := archiveEntry{name: f.Name, mode: f.Mode(), open: f.Open}
+		if entry.mode&os.ModeSymlink != 0 {
+			// zip stores the target of a symlink as its content
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			target, err := ioutil.ReadAll(io.LimitReader(rc, 4096))
+			rc.Close()
+			if err != nil {
+				return err
+			}
+			entry.link = string(target)
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func walkTar(tr *tar.Reader, fn func(entry archiveEntry) error) error {
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		entry := archiveEntry{
+			name: hdr.Name,
+			mode: hdr.FileInfo().Mode(),
+			link: hdr.Linkname,
+			open: func() (io.ReadCloser, error) { return ioutil.NopCloser(tr), nil },
+		}
+		switch hdr.Typeflag {
+		case tar.TypeReg, tar.TypeRegA, tar.TypeDir, tar.TypeSymlink:
+		case tar.TypeLink:
+			entry.hardlink = true
+		default:
+			// devices, fifos and pax headers have no place in a release
+			continue
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
+
+// Extract extracts the zip, tar.gz or tar.xz archive to dest, preserving the file modes, and returns the extracted paths.
+// Entries and links that would end up outside dest are rejected with ErrIllegalPath.
+func Extract(archive, dest string) ([]string, error) {
+	dest = filepath.Clean(dest)
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return nil, errors.Wrapf(err, "cannot create %s", dest)
+	}
+	// entries are written to the real paths, links extracted before are resolved against it
+	realDest, err := filepath.EvalSymlinks(dest)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot resolve %s", dest)
+	}
+	var (
+		filenames []string
+		dirs      = make(map[string]os.FileMode)
+	)
+	err = walkArchive(archive, func(entry archiveEntry) error {
+		// Check for ZipSlip. More Info: http://bit.ly/2MsjAWE
+		fpath, err := securePath(dest, dest, entry.name)
+		if err != nil {
+			return err
+		}
+		rpath, err := resolvePath(realDest, entry.name)
+		if err != nil {
+			return err
+		}
+		filenames = append(filenames, fpath)
+		if entry.mode.IsDir() {
+			dirs[rpath] = entry.mode.Perm()
+			return os.MkdirAll(rpath, 0755)
+		}
+		if err := os.MkdirAll(filepath.Dir(rpath), 0755); err != nil {
+			return err
+		}
+		// never write through a link extracted before
+		if err := os.Remove(rpath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		switch {
+		case entry.hardlink:
+			target, err := resolvePath(realDest, entry.link)
+			if err != nil {
+				return err
+			}
+			return os.Link(target, rpath)
+		case entry.mode&os.ModeSymlink != 0:
+			if filepath.IsAbs(entry.link) {
+				return errors.Wrapf(ErrIllegalPath, "%s: absolute link to %s", entry.name, entry.link)
+			}
+			if _, err := securePath(realDest, filepath.Dir(rpath), entry.link); err != nil {
+				return err
+			}
+			return os.Symlink(entry.link, rpath)
+		default:
+			return writeEntry(entry, rpath)
+		}
+	})
+	if err != nil {
+		return filenames, errors.Wrapf(err, "cannot extract %s", archive)
+	}
+	// directory modes are applied last, a read only directory would not accept its files
+	paths := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		paths = append(paths, dir)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(paths)))
+	for _, dir := range paths {
+		if err := os.Chmod(dir, dirs[dir]); err != nil {
+			return filenames, errors.Wrapf(err, "cannot set the mode of %s", dir)
+		}
+	}
+	return filenames, nil
+}
+
+// securePath joins name to dir and returns an error if the result is not within dest
+func securePath(dest, dir, name string) (string, error) {
+	fpath := filepath.Join(dir, name)
+	if fpath != dest && !strings.HasPrefix(fpath, dest+string(os.PathSeparator)) {
+		return "", errors.Wrapf(ErrIllegalPath, "%s", name)
+	}
+	return fpath, nil
+}
+
+// resolvePath joins name to dest, a real path, resolving the links among the parents of name that exist already.
+// It returns an error if the result is not within dest: links extracted before may each point within dest
+// and still lead out of it once chained, e.g. l -> . then l/e -> .. then l/e/file.
+func resolvePath(dest, name string) (string, error) {
+	fpath, err := securePath(dest, dest, name)
+	if err != nil {
+		return "", err
+	}
+	dir, rest := filepath.Dir(fpath), filepath.Base(fpath)
+	for {
+		if _, err := os.Lstat(dir); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+		dir, rest = filepath.Dir(dir), filepath.Join(filepath.Base(dir), rest)
+	}
+	real, err := filepath.EvalSymlinks(dir)
+	if os.IsNotExist(err) {
+		// a dangling link may point anywhere once its target is extracted
+		return "", errors.Wrapf(ErrIllegalPath, "%s: goes through a dangling link", name)
+	}
+	if err != nil {
+		return "", err
+	}
+	if real != dest && !strings.HasPrefix(real, dest+string(os.PathSeparator)) {
+		return "", errors.Wrapf(ErrIllegalPath, "%s: goes through a link out of the destination", name)
+	}
+	return filepath.Join(real, rest), nil
+}
+
+// writeEntry copies the content of a regular entry to fpath with the mode of the entry
+func writeEntry(entry archiveEntry, fpath string) error {
+	rc, err := entry.open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	out, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, entry.mode.Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, rc); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	// the umask may have stripped permission bits
+	return os.Chmod(fpath, entry.mode.Perm())
+}
@@ -0,0 +1,245 @@
+package runner
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/ulikunitz/xz"
+)
+
+// testEntry is a file, directory (name ending with /), symlink or hardlink of a test archive
+type testEntry struct {
+	name     string
+	mode     os.FileMode
+	content  string
+	symlink  string
+	hardlink string
+}
+
+// buildArchive returns a zip, tar.gz or tar.xz holding entries, zip archives cannot hold hardlinks
+func buildArchive(t *testing.T, format string, entries []testEntry) []byte {
+	var buf bytes.Buffer
+	switch format {
+	case "tar.gz":
+		gz := gzip.NewWriter(&buf)
+		writeTar(t, gz, entries)
+		if err := gz.Close(); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+	case "tar.xz":
+		xw, err := xz.NewWriter(&buf)
+		if err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		writeTar(t, xw, entries)
+		if err := xw.Close(); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+	case "zip":
+		zw := zip.NewWriter(&buf)
+		for _, e := range entries {
+			hdr := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+			content := e.content
+			switch {
+			case e.symlink != "":
+				hdr.SetMode(os.ModeSymlink | 0777)
+				content = e.symlink
+			case e.name[len(e.name)-1] == '/':
+				hdr.SetMode(os.ModeDir | e.mode)
+			default:
+				hdr.SetMode(e.mode)
+			}
+			w, err := zw.CreateHeader(hdr)
+			if err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+			if _, err := w.Write([]byte(content)); err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+		}
+		if err := zw.Close(); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+	}
+	return buf.Bytes()
+}
+
+func writeTar(t *testing.T, w io.Writer, entries []testEntry) {
+	tw := tar.NewWriter(w)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: int64(e.mode), Size: int64(len(e.content)), Typeflag: tar.TypeReg}
+		switch {
+		case e.symlink != "":
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeSymlink, e.symlink, 0
+		case e.hardlink != "":
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeLink, e.hardlink, 0
+		case e.name[len(e.name)-1] == '/':
+			hdr.Typeflag, hdr.Size = tar.TypeDir, 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil && hdr.Size > 0 {
+			t.Error(err)
+			t.FailNow()
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+}
+
+// writeArchive writes the archive built from entries to dir/file
+func writeArchive(t *testing.T, dir, file, format string, entries []testEntry) string {
+	archive := filepath.Join(dir, file)
+	if err := ioutil.WriteFile(archive, buildArchive(t, format, entries), 0644); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	return archive
+}
+
+func TestExtract(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+
+	entries := []testEntry{
+		{name: "pocket-core/", mode: 0750},
+		{name: "pocket-core/bin/pocket", mode: 0755, content: testBinary},
+		{name: "pocket-core/README.md", mode: 0600, content: "pocket-core"},
+		{name: "pocket-core/bin/pocket-core", symlink: "pocket"},
+	}
+	for _, format := range []string{"zip", "tar.gz", "tar.xz"} {
+		t.Run(format, func(t *testing.T) {
+			formatEntries := entries
+			if format != "zip" {
+				formatEntries = append(formatEntries, testEntry{name: "pocket-core/bin/pocketd", hardlink: "pocket-core/bin/pocket"})
+			}
+			// the format is detected from the magic bytes when the file has no extension
+			for _, file := range []string{"release." + format, "release-" + format} {
+				archive := writeArchive(t, dir, file, format, formatEntries)
+				dest := filepath.Join(dir, file+"-extracted")
+				if _, err := Extract(archive, dest); err != nil {
+					t.Error(err)
+					t.FailNow()
+				}
+				expectModes := map[string]os.FileMode{
+					"pocket-core":            os.ModeDir | 0750,
+					"pocket-core/bin/pocket": 0755,
+					"pocket-core/README.md":  0600,
+				}
+				for name, mode := range expectModes {
+					fi, err := os.Stat(filepath.Join(dest, name))
+					if err != nil {
+						t.Error(err)
+						continue
+					}
+					if fi.Mode() != mode {
+						t.Errorf("%s: expected mode %s, got %s", name, mode, fi.Mode())
+					}
+				}
+				if link, err := os.Readlink(filepath.Join(dest, "pocket-core/bin/pocket-core")); err != nil || link != "pocket" {
+					t.Errorf("expected a symlink to pocket, got %q %v", link, err)
+				}
+				if format != "zip" {
+					bin, _ := os.Stat(filepath.Join(dest, "pocket-core/bin/pocket"))
+					linked, err := os.Stat(filepath.Join(dest, "pocket-core/bin/pocketd"))
+					if err != nil || !os.SameFile(bin, linked) {
+						t.Errorf("expected a hardlink to pocket, got %v", err)
+					}
+				}
+				if bz, err := ioutil.ReadFile(filepath.Join(dest, "pocket-core/bin/pocket-core")); err != nil || string(bz) != testBinary {
+					t.Errorf("unexpected content %q %v", bz, err)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractIllegalPaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+
+	cases := map[string]struct {
+		format  string
+		entries []testEntry
+	}{
+		"zip slip":             {format: "zip", entries: []testEntry{{name: "../evil", mode: 0644, content: "evil"}}},
+		"tar slip":             {format: "tar.gz", entries: []testEntry{{name: "release/../../evil", mode: 0644, content: "evil"}}},
+		"absolute symlink":     {format: "tar.xz", entries: []testEntry{{name: "passwd", symlink: "/etc/passwd"}}},
+		"escaping symlink":     {format: "tar.gz", entries: []testEntry{{name: "release/up", symlink: "../../"}}},
+		"escaping zip symlink": {format: "zip", entries: []testEntry{{name: "up", symlink: "../outside"}}},
+		"escaping hardlink":    {format: "tar.gz", entries: []testEntry{{name: "passwd", hardlink: "../../etc/passwd"}}},
+		"chained symlinks": {format: "tar.gz", entries: []testEntry{
+			{name: "l", symlink: "."},
+			{name: "l/e", symlink: ".."},
+			{name: "l/e/evil", mode: 0644, content: "evil"},
+		}},
+		"chained zip symlinks": {format: "zip", entries: []testEntry{
+			{name: "l", symlink: "."},
+			{name: "l/e", symlink: ".."},
+			{name: "l/e/evil", mode: 0644, content: "evil"},
+		}},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			archive := writeArchive(t, dir, "release."+tc.format, tc.format, tc.entries)
+			dest := filepath.Join(dir, "extracted", name)
+			if _, err := Extract(archive, dest); errors.Cause(err) != ErrIllegalPath {
+				t.Errorf("expected ErrIllegalPath, got %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "extracted", "evil")); !os.IsNotExist(err) {
+				t.Errorf("a file was written outside of the destination: %v", err)
+			}
+		})
+	}
+}
+
+func TestDetectArchiveFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "artifact")
+	if err := ioutil.WriteFile(file, []byte("#!/bin/sh"), 0644); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if _, err := DetectArchiveFormat(file); errors.Cause(err) != ErrUnsupportedArchive {
+		t.Errorf("expected ErrUnsupportedArchive, got %v", err)
+	}
+	for _, format := range []string{"zip", "tar.gz", "tar.xz"} {
+		archive := writeArchive(t, dir, "artifact", format, []testEntry{{name: "pocket", mode: 0755, content: testBinary}})
+		detected, err := DetectArchiveFormat(archive)
+		if err != nil || detected.String() != format {
+			t.Errorf("expected %s, got %s %v", format, detected, err)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package runner
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pokt-network/pocket-runner/internal/types"
+)
+
+const partialBackupSuffix = ".partial"
+
+// BackupData snapshots pocket-core's data directory before upgrade is applied, returns the path of the
+// snapshot or "" if none was taken. pocket-core must not be running.
+func BackupData(cfg *types.Config, upgrade *types.UpgradeInfo) (string, error) {
+	if cfg.Backup.Skip {
+		log.Printf("skipping the data backup before upgrade %s\n", upgrade.Name)
+		return "", nil
+	}
+	src := cfg.DataDir()
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		log.Printf("no data found at %s, nothing to back up before upgrade %s\n", src, upgrade.Name)
+		return "", nil
+	} else if err != nil {
+		return "", errors.Wrapf(err, "cannot stat data dir %s", src)
+	}
+	if err := os.MkdirAll(cfg.BackupsDir(), 0755); err != nil {
+		return "", errors.Wrap(err, "cannot create backups dir")
+	}
+
+	// copy into a partial dir first so an interrupted backup is never mistaken for a complete one
+	dst := cfg.BackupDir(upgrade)
+	partial := dst + partialBackupSuffix
+	if err := os.RemoveAll(partial); err != nil {
+		return "", errors.Wrapf(err, "cannot remove previous partial backup %s", partial)
+	}
+	log.Printf("backing up %s to %s\n", src, dst)
+	start := time.Now()
+	if err := types.Copy(src, partial, types.Options{Recursive: true, Atomic: true}); err != nil {
+		os.RemoveAll(partial)
+		return "", errors.Wrapf(err, "cannot back up %s", src)
+	}
+	// a previous attempt of the same upgrade left a backup behind, the new one is more recent
+	if err := os.RemoveAll(dst); err != nil {
+		return "", errors.Wrapf(err, "cannot replace backup %s", dst)
+	}
+	if err := os.Rename(partial, dst); err != nil {
+		return "", errors.Wrapf(err, "cannot complete backup %s", dst)
+	}
+	log.Printf("backed up %s in %s\n", src, time.Since(start).Round(time.Millisecond))
+
+	if err := PruneBackups(cfg); err != nil {
+		log.Printf("could not remove old backups: %v\n", err)
+	}
+	return dst, nil
+}
+
+// PruneBackups removes the oldest backups so only the number configured by the backup policy is left
+func PruneBackups(cfg *types.Config) error {
+	if cfg.Backup.Keep <= 0 {
+		return nil
+	}
+	entries, err := ioutil.ReadDir(cfg.BackupsDir())
+	if err != nil {
+		return errors.Wrap(err, "cannot list backups")
+	}
+	backups := entries[:0]
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasSuffix(entry.Name(), partialBackupSuffix) {
+			backups = append(backups, entry)
+		}
+	}
+	if len(backups) <= cfg.Backup.Keep {
+		return nil
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].ModTime().Before(backups[j].ModTime()) })
+	for _, backup := range backups[:len(backups)-cfg.Backup.Keep] {
+		path := filepath.Join(cfg.BackupsDir(), backup.Name())
+		log.Printf("removing old backup %s\n", path)
+		if err := os.RemoveAll(path); err != nil {
+			return errors.Wrapf(err, "cannot remove backup %s", path)
+		}
+	}
+	return nil
+}
+
+// RestoreBackup replaces pocket-core's data directory with backup. The data written by the failed
+// upgrade is moved next to the data directory rather than deleted. pocket-core must not be running.
+func RestoreBackup(cfg *types.Config, backup, upgradeName string) error {
+	dataDir := cfg.DataDir()
+	failed := fmt.Sprintf("%s.%s-failed", dataDir, url.PathEscape(upgradeName))
+	if err := os.RemoveAll(failed); err != nil {
+		return errors.Wrapf(err, "cannot remove %s", failed)
+	}
+	if err := os.Rename(dataDir, failed); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "cannot move the data of upgrade %s aside", upgradeName)
+	}
+	log.Printf("restoring %s from %s, the data of upgrade %s was moved to %s\n", dataDir, backup, upgradeName, failed)
+	if err := types.Copy(backup, dataDir, types.Options{Recursive: true, Atomic: true}); err != nil {
+		return errors.Wrapf(err, "cannot restore %s", backup)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package runner
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pokt-network/pocket-runner/internal/types"
+)
+
+func TestBackupData(t *testing.T) {
+	home, err := copyTestData("validate")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(home)
+	cfg := &types.Config{Home: home, Name: "test-runnerd", Backup: types.BackupPolicy{Keep: 2}}
+
+	// nothing to back up yet
+	if dst, err := BackupData(cfg, &types.UpgradeInfo{Name: "RC-0.1.0", Height: 5}); err != nil || dst != "" {
+		t.Errorf("expected no backup without data, got %q %v", dst, err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(cfg.DataDir(), "application.db"), 0755); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err := ioutil.WriteFile(filepath.Join(cfg.DataDir(), "application.db", "000001.ldb"), []byte("state"), 0644); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	upgrades := []*types.UpgradeInfo{
+		{Name: "RC-0.2.0", Height: 10},
+		{Name: "RC-0.2.1", Height: 20},
+		{Name: "RC-0.3.0", Height: 30},
+	}
+	start := time.Now().Add(-time.Hour)
+	for i, upgrade := range upgrades {
+		dst, err := BackupData(cfg, upgrade)
+		if err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		if dst != filepath.Join(home, "runner", "backups", fmt.Sprintf("%s-%d", upgrade.Name, upgrade.Height)) {
+			t.Errorf("unexpected backup dir %s", dst)
+		}
+		bz, err := ioutil.ReadFile(filepath.Join(dst, "application.db", "000001.ldb"))
+		if err != nil || string(bz) != "state" {
+			t.Errorf("backup %s does not hold the data: %q %v", dst, bz, err)
+		}
+		// make the order of the backups explicit
+		ts := start.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(dst, ts, ts); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+	}
+	if err := PruneBackups(cfg); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	entries, err := ioutil.ReadDir(cfg.BackupsDir())
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(entries) != 2 || entries[0].Name() != "RC-0.2.1-20" || entries[1].Name() != "RC-0.3.0-30" {
+		t.Errorf("expected the 2 most recent backups to be kept, got %d entries", len(entries))
+	}
+
+	cfg.Backup.Skip = true
+	if dst, err := BackupData(cfg, &types.UpgradeInfo{Name: "RC-0.4.0", Height: 40}); err != nil || dst != "" {
+		t.Errorf("expected the backup to be skipped, got %q %v", dst, err)
+	}
+}
@@ -0,0 +1,204 @@
+package runner
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pokt-network/pocket-runner/internal/types"
+)
+
+// buildLogTail is how many lines of the build log are quoted in the error of a failed build
+const buildLogTail = 20
+
+// buildEnvPassthrough are the variables of the runner passed to source builds, module downloads may need them
+var buildEnvPassthrough = []string{
+	"PATH", "GOROOT", "GOPROXY", "GOPRIVATE", "GONOPROXY", "GONOSUMDB", "GOSUMDB",
+	"CGO_ENABLED", "CC", "CXX", "HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY",
+}
+
+// commitPattern matches a full git commit hash
+var commitPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// ErrBuildFailed occurs when the source of an upgrade does not compile
+var ErrBuildFailed = errors.New("build failed")
+
+// CompilePocketCore builds the source of the upgrade extracted to cfg.DownloadCode into cfg.UpgradeBin, following the build policy.
+// The build runs with an isolated GOPATH, GOCACHE and HOME, its output is written to cfg.BuildLog
+// and cfg.BuildInfoFile records the toolchain, the commit and the hash of the binary. commit may be empty if it is not known.
+func CompilePocketCore(cfg *types.Config, info *types.UpgradeInfo, commit string) error {
+	policy := cfg.Build.WithDefaults()
+	root, err := sourceRoot(cfg.DownloadCode(info.Name))
+	if err != nil {
+		return err
+	}
+	if commit == "" {
+		commit = gitCommit(root)
+	}
+	main := filepath.Join(root, filepath.FromSlash(policy.Main))
+	if _, err := os.Stat(main); err != nil {
+		return errors.Wrapf(err, "main package %s not found in the source of %s, set DAEMON_BUILD_MAIN", policy.Main, info.Name)
+	}
+	env, err := buildEnv(cfg.BuildDir(info.Name), policy)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"build", "-o", cfg.UpgradeBin(info.Name)}
+	if !policy.NoTrimPath {
+		args = append(args, "-trimpath")
+	}
+	if policy.LDFlags != "" {
+		args = append(args, "-ldflags", policy.ExpandLDFlags(info.Name, commit))
+	}
+	// building from the source root keeps the module of pocket-core in use
+	args = append(args, "./"+filepath.ToSlash(policy.Main))
+
+	logFile := cfg.BuildLog(info.Name)
+	out, err := os.Create(logFile)
+	if err != nil {
+		return errors.Wrap(err, "cannot create build log")
+	}
+	defer out.Close()
+	fmt.Fprintf(out, "# go %s\n# in %s\n", strings.Join(args, " "), root)
+	for _, v := range env {
+		// proxy urls may hold credentials
+		if name := v[:strings.Index(v, "=")]; !strings.HasSuffix(name, "_PROXY") {
+			fmt.Fprintf(out, "# %s\n", v)
+		}
+	}
+
+	cmd := exec.Command("go", args...)
+	cmd.Dir = root
+	cmd.Env = env
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := cmd.Run(); err != nil {
+		return errors.Wrapf(ErrBuildFailed, "go build of %s: %v, see %s:\n%s", info.Name, err, logFile, tail(logFile, buildLogTail))
+	}
+	if err := types.CheckBinary(cfg.UpgradeBin(info.Name)); err != nil {
+		return errors.Wrapf(err, "upgrade %s not found", info.Name)
+	}
+
+	version := exec.Command("go", "version")
+	version.Env = env
+	toolchain, err := version.Output()
+	if err != nil {
+		return errors.Wrap(err, "cannot read the go version")
+	}
+	binary, err := fileSHA256(cfg.UpgradeBin(info.Name))
+	if err != nil {
+		return err
+	}
+	return writeBuildInfo(cfg.BuildInfoFile(info.Name), types.BuildInfo{
+		Upgrade:   info.Name,
+		Toolchain: strings.TrimSpace(string(toolchain)),
+		Commit:    commit,
+		Main:      policy.Main,
+		LDFlags:   policy.ExpandLDFlags(info.Name, commit),
+		TrimPath:  !policy.NoTrimPath,
+		GoFlags:   policy.GoFlags,
+		Binary:    binary,
+		BuiltAt:   time.Now().UTC(),
+	})
+}
+
+// buildEnv returns the environment of a build whose GOPATH, GOCACHE and HOME live in dir
+func buildEnv(dir string, policy types.BuildPolicy) ([]string, error) {
+	paths := map[string]string{
+		"GOPATH":  filepath.Join(dir, "gopath"),
+		"GOCACHE": filepath.Join(dir, "gocache"),
+		"HOME":    filepath.Join(dir, "home"),
+	}
+	env := make([]string, 0, len(buildEnvPassthrough)+len(paths)+1)
+	for _, name := range []string{"GOPATH", "GOCACHE", "HOME"} {
+		if err := os.MkdirAll(paths[name], 0755); err != nil {
+			return nil, errors.Wrapf(err, "cannot create the build %s", name)
+		}
+		env = append(env, name+"="+paths[name])
+	}
+	for _, name := range buildEnvPassthrough {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return append(env, "GOFLAGS="+policy.GoFlags), nil
+}
+
+// sourceRoot returns the root of the source extracted to dir.
+// Source archives usually hold a single top level directory, e.g. pocket-core-<version>.
+func sourceRoot(dir string) (string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot read the source")
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		return filepath.Join(dir, entries[0].Name()), nil
+	}
+	return dir, nil
+}
+
+// gitCommit returns the commit checked out at root, empty if root is not a git checkout
+func gitCommit(root string) string {
+	if _, err := os.Stat(filepath.Join(root, ".git")); err != nil {
+		return ""
+	}
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	cmd.Dir = root
+	bz, err := cmd.Output()
+	if commit := strings.TrimSpace(string(bz)); err == nil && commitPattern.MatchString(commit) {
+		return commit
+	}
+	return ""
+}
+
+// archiveCommit returns the commit GitHub stores in the comment of the zip archives of a repository, empty if there is none
+func archiveCommit(archive string) string {
+	if format, err := DetectArchiveFormat(archive); err != nil || format != FormatZip {
+		return ""
+	}
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return ""
+	}
+	defer r.Close()
+	if commit := strings.TrimSpace(r.Comment); commitPattern.MatchString(commit) {
+		return commit
+	}
+	return ""
+}
+
+// writeBuildInfo writes info to file as indented json
+func writeBuildInfo(file string, info types.BuildInfo) error {
+	bz, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "cannot encode build info")
+	}
+	return errors.Wrapf(ioutil.WriteFile(file, bz, 0644), "cannot write %s", file)
+}
+
+// tail returns the last n lines of file
+func tail(file string, n int) string {
+	bz, err := ioutil.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(bz))
+	for scanner.Scan() {
+		if lines = append(lines, scanner.Text()); len(lines) > n {
+			lines = lines[1:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,131 @@
+package runner
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/pokt-network/pocket-runner/internal/types"
+)
+
+const testMain = `package main
+
+import "fmt"
+
+var version = "dev"
+
+func main() { fmt.Println(version) }
+`
+
+// writeSource writes a pocket-core like module to the download dir of the upgrade, under a top level directory as GitHub archives do
+func writeSource(t *testing.T, cfg *types.Config, upgrade, main string) {
+	root := filepath.Join(cfg.DownloadCode(upgrade), "pocket-core-"+upgrade)
+	files := map[string]string{
+		"go.mod":                      "module github.com/pokt-network/pocket-core\n\ngo 1.13\n",
+		"app/cmd/pocket_core/main.go": main,
+	}
+	for name, content := range files {
+		file := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+	}
+}
+
+func TestCompilePocketCore(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil || testing.Short() {
+		t.Skip("builds need a go toolchain and compile the standard library in a fresh cache")
+	}
+	home, err := copyTestData("validate")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(home)
+
+	const upgrade, commit = "RC-0.3.0", "0123456789abcdef0123456789abcdef01234567"
+	cfg := &types.Config{Home: home, Name: "test-runnerd", Build: types.BuildPolicy{LDFlags: "-X main.version={version}"}}
+	writeSource(t, cfg, upgrade, testMain)
+	if err := CompilePocketCore(cfg, &types.UpgradeInfo{Name: upgrade}, commit); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	out, err := exec.Command(cfg.UpgradeBin(upgrade)).Output()
+	if err != nil || strings.TrimSpace(string(out)) != upgrade {
+		t.Errorf("expected the binary to print %s, got %q %v", upgrade, out, err)
+	}
+	bz, err := ioutil.ReadFile(cfg.BuildInfoFile(upgrade))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	var info types.BuildInfo
+	if err := json.Unmarshal(bz, &info); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	binary, err := fileSHA256(cfg.UpgradeBin(upgrade))
+	if err != nil {
+		t.Error(err)
+	}
+	if info.Upgrade != upgrade || info.Commit != commit || info.Binary != binary || !info.TrimPath ||
+		info.LDFlags != "-X main.version="+upgrade || !strings.HasPrefix(info.Toolchain, "go version") {
+		t.Errorf("unexpected build info %+v", info)
+	}
+	// the build does not touch the cache of the runner's environment
+	if entries, err := ioutil.ReadDir(filepath.Join(cfg.BuildDir(upgrade), "gocache")); err != nil || len(entries) == 0 {
+		t.Errorf("expected the build cache under the upgrade dir, got %v", err)
+	}
+	if log, err := ioutil.ReadFile(cfg.BuildLog(upgrade)); err != nil || !strings.Contains(string(log), "-trimpath") {
+		t.Errorf("expected the build command in the build log, got %q %v", log, err)
+	}
+}
+
+func TestCompilePocketCoreFailure(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil || testing.Short() {
+		t.Skip("builds need a go toolchain and compile the standard library in a fresh cache")
+	}
+	home, err := copyTestData("validate")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(home)
+
+	cases := map[string]struct {
+		build     types.BuildPolicy
+		main      string
+		expectErr string
+	}{
+		"compile error": {main: "package main\n\nfunc main() { undefined() }\n", expectErr: "undefined"},
+		"missing main":  {build: types.BuildPolicy{Main: "cmd/pocket"}, main: testMain, expectErr: "DAEMON_BUILD_MAIN"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cfg := &types.Config{Home: home, Name: "test-runnerd", Build: tc.build}
+			upgrade := "RC-" + strings.Replace(name, " ", "-", -1)
+			writeSource(t, cfg, upgrade, tc.main)
+			err := CompilePocketCore(cfg, &types.UpgradeInfo{Name: upgrade}, "")
+			if err == nil || !strings.Contains(err.Error(), tc.expectErr) {
+				t.Errorf("expected an error mentioning %q, got %v", tc.expectErr, err)
+			}
+			if tc.expectErr == "undefined" && errors.Cause(err) != ErrBuildFailed {
+				t.Errorf("expected ErrBuildFailed, got %v", err)
+			}
+			if _, err := os.Stat(cfg.BuildInfoFile(upgrade)); !os.IsNotExist(err) {
+				t.Errorf("no build info must be written for a failed build, got %v", err)
+			}
+		})
+	}
+}
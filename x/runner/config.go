@@ -0,0 +1,67 @@
+package runner
+
+import (
+	"io"
+
+	"github.com/pokt-network/pocket-runner/internal/types"
+)
+
+// The types package is internal to pocket-runner, the config of a Runner and the policies it is made of
+// are aliased here so programs embedding a Runner can build one.
+type (
+	// Config is the configuration of a Runner, see types.Config
+	Config = types.Config
+	// RestartPolicy see types.RestartPolicy
+	RestartPolicy = types.RestartPolicy
+	// RestartMode see types.RestartMode
+	RestartMode = types.RestartMode
+	// ReadinessProbe see types.ReadinessProbe
+	ReadinessProbe = types.ReadinessProbe
+	// ShutdownPolicy see types.ShutdownPolicy
+	ShutdownPolicy = types.ShutdownPolicy
+	// BackupPolicy see types.BackupPolicy
+	BackupPolicy = types.BackupPolicy
+	// RollbackPolicy see types.RollbackPolicy
+	RollbackPolicy = types.RollbackPolicy
+	// VerifyPolicy see types.VerifyPolicy
+	VerifyPolicy = types.VerifyPolicy
+	// DownloadPolicy see types.DownloadPolicy
+	DownloadPolicy = types.DownloadPolicy
+	// Mirror see types.Mirror
+	Mirror = types.Mirror
+	// BuildPolicy see types.BuildPolicy
+	BuildPolicy = types.BuildPolicy
+	// PassphrasePolicy see types.PassphrasePolicy
+	PassphrasePolicy = types.PassphrasePolicy
+	// PassphraseSource see types.PassphraseSource
+	PassphraseSource = types.PassphraseSource
+	// UpgradeInfo is an upgrade scheduled on chain, see types.UpgradeInfo
+	UpgradeInfo = types.UpgradeInfo
+	// Setting is an option of runner.toml, the environment and the flags, see types.Setting
+	Setting = types.Setting
+)
+
+// DefaultConfig returns the config of the binary name installed under home, with the default port and policies
+func DefaultConfig(home, name string) *Config {
+	return types.DefaultConfig(home, name)
+}
+
+// ConfigFromEnv reads the config from the DAEMON_* environment variables and validates it
+func ConfigFromEnv() (*Config, error) {
+	return types.GetConfigFromEnv()
+}
+
+// LoadConfig merges runner.toml, the environment variables and settings, keyed by setting, into a config and validates it
+func LoadConfig(settings map[string]string) (*Config, error) {
+	return types.LoadConfig(settings)
+}
+
+// LookupSetting returns the setting set by key in runner.toml and by the -key flag
+func LookupSetting(key string) (Setting, bool) {
+	return types.LookupSetting(key)
+}
+
+// ShowConfig writes cfg as a runner.toml annotated with where each setting was read from, secrets are redacted
+func ShowConfig(w io.Writer, cfg *Config) error {
+	return types.ShowConfig(w, cfg)
+}
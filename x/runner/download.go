@@ -1,182 +1,95 @@
 package runner
 
 import (
-	"archive/zip"
-	"fmt"
+	"context"
 	"github.com/pkg/errors"
 	"github.com/pokt-network/pocket-runner/internal/types"
-	"io"
-	"net/http"
+	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"strings"
 )
 
 const (
 	zipExtension = ".zip"
 )
 
-//This should be updated with new mirrors
-var knownMirrors = []string{"https://github.com/pokt-network/pocket-core/archive/"}
-
-func DownloadBinary(cfg *types.Config, info *types.UpgradeInfo) error {
-
-	//Get Mirrors Links for Version
-	mirrors, err := GetMirrorLinksForVersion(info.Name)
+// DownloadBinary installs the binary of the upgrade, from the prebuilt release when one is configured
+// and by compiling the source otherwise
+func DownloadBinary(ctx context.Context, cfg *types.Config, info *types.UpgradeInfo) error {
+	if len(cfg.Download.ReleaseMirrors) != 0 {
+		err := DownloadRelease(ctx, cfg, info)
+		if err == nil {
+			return nil
+		}
+		if cfg.Download.SkipCompile || ctx.Err() != nil {
+			return err
+		}
+		log.Printf("could not install the release of %s, building it from source: %v\n", info.Name, err)
+	}
+	return DownloadSource(ctx, cfg, info)
+}
 
-	if err != nil {
-		return err
+// DownloadSource downloads the source of the upgrade and compiles it, it requires a go toolchain
+func DownloadSource(ctx context.Context, cfg *types.Config, info *types.UpgradeInfo) error {
+	mirrors := cfg.Download.SourceMirrors
+	if len(mirrors) == 0 {
+		return errors.New("no source mirror is set")
 	}
 
-	for _, link := range mirrors {
+	archive := ""
+	for _, mirror := range mirrors {
+		link := mirror.Link(cfg.Name, info.Name)
 		//Download File from mirror
-		result := DownloadFile(cfg, info, link)
-		if result {
-			//if successfull break
-			break
+		file, err := DownloadFile(ctx, cfg, info, mirror)
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			log.Printf("%v\n", err)
+			continue
+		}
+		// nothing is extracted or executed unless it is the expected artifact
+		if err := VerifyArtifact(cfg, info, link, file); err != nil {
+			log.Printf("rejecting %s: %v\n", link, err)
+			os.Remove(file)
+			continue
 		}
+		//if successfull break
+		archive = file
+		break
 	}
-
-	_, err = os.Stat(cfg.DownloadCode(info.Name) + zipExtension)
-	if os.IsNotExist(err) {
-		return err
+	if archive == "" {
+		return errors.Errorf("could not download a verified release of %s from %d mirrors", info.Name, len(mirrors))
 	}
 
-	//unzip file
-	_, err = Unzip(cfg.DownloadCode(info.Name)+zipExtension, cfg.DownloadCode(info.Name))
+	//extract the source
+	_, err := Extract(archive, cfg.DownloadCode(info.Name))
 	if err != nil {
-		fmt.Println(err.Error())
 		return err
 	}
 	//delete unziped code folder
 	defer os.RemoveAll(cfg.DownloadCode(info.Name))
 
-	err = CompilePocketCore(cfg, info)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func CompilePocketCore(cfg *types.Config, info *types.UpgradeInfo) error {
-	//compile binary
-	pathToMain := filepath.Join(cfg.DownloadCode(info.Name), "pocket-core-"+info.Name, "app", "cmd", "pocket_core", "main.go")
-
-	cmd := exec.Command("go", "build", "-o", cfg.UpgradeBin(info.Name), pathToMain)
-	stdout, err := cmd.Output()
-
+	err = CompilePocketCore(cfg, info, archiveCommit(archive))
 	if err != nil {
-		errors.Wrap(err, "Error building")
 		return err
 	}
-	fmt.Print(string(stdout))
-
-	_, err = os.Stat(cfg.UpgradeBin(info.Name))
-	if os.IsNotExist(err) {
-		return errors.Wrapf(err, "upgrade %s not found", info.Name)
-	}
 
 	return nil
 }
 
-func DownloadFile(cfg *types.Config, info *types.UpgradeInfo, link string) bool {
-
-	path := cfg.DownloadCode(info.Name)
-
-	// Create the file
-	// this will fail if ../release/code doesnt exist
-	out, err := os.Create(path + zipExtension)
-	if err != nil {
-		return false
+// DownloadFile downloads the source archive of the upgrade from mirror, resuming a previous attempt if possible.
+// It returns the path of the archive, named after the format of the mirror.
+func DownloadFile(ctx context.Context, cfg *types.Config, info *types.UpgradeInfo, mirror types.Mirror) (string, error) {
+	link := mirror.Link(cfg.Name, info.Name)
+	file := cfg.DownloadCode(info.Name) + archiveExtension(link)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return "", errors.Wrap(err, "cannot create code dir")
 	}
-	defer out.Close()
-
-	resp, err := http.Get(link)
-	if err != nil {
-		return false
-	}
-	defer resp.Body.Close()
-
-	_, err = io.Copy(out, resp.Body)
-	if err != nil {
-		return false
-	}
-
-	return true
-}
-
-func GetMirrorLinksForVersion(version string) ([]string, error) {
-
-	versionLinks := make([]string, 0, 10)
-
-	if version != "" {
-
-		for _, mirror := range knownMirrors {
-
-			versionLinks = append(versionLinks, mirror+version+".zip")
-		}
-
-		return versionLinks, nil
-	} else {
-		return versionLinks, errors.New("No Version")
-	}
-
+	return file, Download(ctx, link, file, downloadOptions(cfg, mirror))
 }
 
+// Unzip extracts the archive src to dest, see Extract
 func Unzip(src string, dest string) ([]string, error) {
-
-	var filenames []string
-
-	r, err := zip.OpenReader(src)
-	if err != nil {
-		return filenames, err
-	}
-	defer r.Close()
-
-	for _, f := range r.File {
-
-		// Store filename/path for returning and using later on
-		fpath := filepath.Join(dest, f.Name)
-
-		// Check for ZipSlip. More Info: http://bit.ly/2MsjAWE
-		if !strings.HasPrefix(fpath, filepath.Clean(dest)+string(os.PathSeparator)) {
-			return filenames, fmt.Errorf("%s: illegal file path", fpath)
-		}
-
-		filenames = append(filenames, fpath)
-
-		if f.FileInfo().IsDir() {
-			// Make Folder
-			os.MkdirAll(fpath, os.ModePerm)
-			continue
-		}
-
-		// Make File
-		if err = os.MkdirAll(filepath.Dir(fpath), os.ModePerm); err != nil {
-			return filenames, err
-		}
-
-		outFile, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
-		if err != nil {
-			return filenames, err
-		}
-
-		rc, err := f.Open()
-		if err != nil {
-			return filenames, err
-		}
-
-		_, err = io.Copy(outFile, rc)
-
-		// Close the file without defer to close before next iteration of loop
-		outFile.Close()
-		rc.Close()
-
-		if err != nil {
-			return filenames, err
-		}
-	}
-	return filenames, nil
+	return Extract(src, dest)
 }
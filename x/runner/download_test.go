@@ -1,12 +1,41 @@
 package runner
 
 import (
+	"context"
 	"github.com/pokt-network/pocket-runner/internal/types"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
 	"testing"
+	"time"
 )
 
+// newSourceMirror serves the source of version at /<version>.zip as GitHub does, and the SHA256SUMS GitHub does not publish
+func newSourceMirror(t *testing.T, version, main string) *httptest.Server {
+	source := buildArchive(t, "zip", []testEntry{
+		{name: "pocket-core-" + version + "/go.mod", mode: 0644, content: "module github.com/pokt-network/pocket-core\n\ngo 1.13\n"},
+		{name: "pocket-core-" + version + "/app/cmd/pocket_core/main.go", mode: 0644, content: main},
+	})
+	files := map[string][]byte{
+		"/" + version + ".zip": source,
+		"/SHA256SUMS":          []byte(sha256Hex(source) + "  " + version + ".zip\n"),
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bz, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(bz)
+	}))
+}
+
 func TestDownloadBinary(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil || testing.Short() {
+		t.Skip("builds need a go toolchain and compile the standard library in a fresh cache")
+	}
 	home, err := copyTestData("validate")
 	if err != nil {
 		t.Error(err)
@@ -14,7 +43,12 @@ func TestDownloadBinary(t *testing.T) {
 	}
 	defer os.RemoveAll(home)
 
-	cfg := &types.Config{Home: home, Name: "test-runnerd"}
+	server := newSourceMirror(t, "RC-0.2.1", testMain)
+	defer server.Close()
+
+	cfg := &types.Config{Home: home, Name: "test-runnerd", Download: types.DownloadPolicy{
+		SourceMirrors: []types.Mirror{{URL: server.URL + "/{version}.zip", Timeout: time.Second}},
+	}}
 
 	type args struct {
 		cfg  *types.Config
@@ -33,12 +67,57 @@ func TestDownloadBinary(t *testing.T) {
 			}},
 			wantErr: false,
 		},
+		{
+			name: "unpublishedVersion",
+			args: args{cfg: cfg, info: &types.UpgradeInfo{
+				Name:    "RC-0.2.2",
+				Version: "RC-0.2.2",
+			}},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if err := DownloadBinary(tt.args.cfg, tt.args.info); (err != nil) != tt.wantErr {
+			if err := DownloadBinary(context.Background(), tt.args.cfg, tt.args.info); (err != nil) != tt.wantErr {
 				t.Errorf("DownloadBinary() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
+	if err := types.CheckBinary(cfg.UpgradeBin("RC-0.2.1")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDownloadFile(t *testing.T) {
+	home, err := copyTestData("validate")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(home)
+
+	const source = "pocket-core source"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/RC-0.9.0.zip" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(source))
+	}))
+	defer server.Close()
+
+	// nothing is staged for RC-0.9.0, the code dir does not exist yet
+	cfg := &types.Config{Home: home, Name: "test-runnerd"}
+	info := &types.UpgradeInfo{Name: "RC-0.9.0", Version: "RC-0.9.0"}
+	if _, err := os.Stat(cfg.UpgradeDir(info.Name)); !os.IsNotExist(err) {
+		t.Errorf("expected %s not to be staged, got %v", info.Name, err)
+	}
+	file, err := DownloadFile(context.Background(), cfg, info, types.Mirror{URL: server.URL + "/{version}.zip", Timeout: time.Second})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if bz, err := ioutil.ReadFile(file); err != nil || string(bz) != source {
+		t.Errorf("unexpected download %q %v", bz, err)
+	}
 }
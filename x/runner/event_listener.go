@@ -4,68 +4,269 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sync"
+	"time"
 
 	// tmCfg "github.com/tendermint/tendermint/config"
+	"github.com/pkg/errors"
 	"github.com/pokt-network/pocket-runner/internal/types"
 	"github.com/tendermint/tendermint/rpc/client"
 	coreTypes "github.com/tendermint/tendermint/rpc/core/types"
-	tmTypes"github.com/tendermint/tendermint/types"
+	tmTypes "github.com/tendermint/tendermint/types"
 )
 
-const defaultListenAddr = "tcp://0.0.0.0:"
+const (
+	subscriber          = "helpers"
+	eventBufferSize     = 100
+	minReconnectBackoff = time.Second
+	maxReconnectBackoff = 30 * time.Second
+)
+
+// healthCheckInterval is how often the listener checks that pocket-core and the websocket are still alive
+var healthCheckInterval = 10 * time.Second
+
+// ConnectionState is the state of the listener's connection to pocket-core
+type ConnectionState int
+
+const (
+	StateDisconnected ConnectionState = iota
+	StateConnecting
+	StateConnected
+	StateStopped
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case StateDisconnected:
+		return "disconnected"
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateStopped:
+		return "stopped"
+	}
+	return "unknown"
+}
+
+// EventListener forwards pocket-core transactions & block headers.
+// TxChan and HeaderChan survive reconnections: when pocket-core restarts or the websocket drops,
+// the listener reconnects with backoff and resubscribes on its own.
 type EventListener struct {
-	client     client.Client
+	cfg        *types.Config
 	TxChan     <-chan coreTypes.ResultEvent
 	HeaderChan <-chan coreTypes.ResultEvent
+	txChan     chan coreTypes.ResultEvent
+	headerChan chan coreTypes.ResultEvent
+	states     chan ConnectionState
+	state      ConnectionState
+	mu         sync.Mutex
 	ctx        context.Context
 	cancel     func()
+	done       chan struct{}
+}
+
+// subscription is a single websocket connection to pocket-core
+type subscription struct {
+	client  client.Client
+	status  client.Client
+	height  int64
+	txs     <-chan coreTypes.ResultEvent
+	headers <-chan coreTypes.ResultEvent
 }
 
-func NewEventListener(cfg *types.Config) *EventListener {
-	tmClient := TMClient(cfg.GetPort())
+// NewEventListener waits for pocket-core's rpc to be ready and subscribes to transactions & block headers
+func NewEventListener(cfg *types.Config) (*EventListener, error) {
 	ctx, cancel := context.WithCancel(context.Background())
-	txChan := subscribeToEvent(tmClient, ctx, tmTypes.EventTx)
-	headerChan := subscribeToEvent(tmClient, ctx, tmTypes.EventNewBlockHeader)
-	return &EventListener{
-		client:     tmClient,
-		ctx:        ctx,
+	txChan := make(chan coreTypes.ResultEvent, eventBufferSize)
+	headerChan := make(chan coreTypes.ResultEvent, eventBufferSize)
+	el := &EventListener{
+		cfg:        cfg,
 		TxChan:     txChan,
 		HeaderChan: headerChan,
+		txChan:     txChan,
+		headerChan: headerChan,
+		states:     make(chan ConnectionState, eventBufferSize),
+		ctx:        ctx,
 		cancel:     cancel,
+		done:       make(chan struct{}),
 	}
+	sub, err := el.connect()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	go el.run(sub)
+	return el, nil
+}
+
+// States reports every connection state change, changes are dropped if nobody reads them
+func (el *EventListener) States() <-chan ConnectionState {
+	return el.states
+}
+
+// State returns the current connection state
+func (el *EventListener) State() ConnectionState {
+	el.mu.Lock()
+	defer el.mu.Unlock()
+	return el.state
 }
 
-func subscribeToEvent(client client.Client, ctx context.Context, evt string) <-chan coreTypes.ResultEvent {
+func (el *EventListener) setState(state ConnectionState) {
+	el.mu.Lock()
+	changed := el.state != state
+	el.state = state
+	el.mu.Unlock()
+	if !changed {
+		return
+	}
+	select {
+	case el.states <- state:
+	default:
+	}
+}
+
+// connect waits for pocket-core to be ready and subscribes to the events the runner needs
+func (el *EventListener) connect() (*subscription, error) {
+	el.setState(StateConnecting)
+	status, err := WaitForReady(el.ctx, el.cfg)
+	if err != nil {
+		return nil, err
+	}
+	tmClient := TMClient(el.cfg.RPCAddress())
+	txs, err := subscribeToEvent(tmClient, el.ctx, tmTypes.EventTx)
+	if err != nil {
+		_ = tmClient.Stop()
+		return nil, err
+	}
+	headers, err := subscribeToEvent(tmClient, el.ctx, tmTypes.EventNewBlockHeader)
+	if err != nil {
+		_ = tmClient.Stop()
+		return nil, err
+	}
+	el.setState(StateConnected)
+	return &subscription{
+		client:  tmClient,
+		status:  newStatusClient(el.cfg),
+		height:  status.SyncInfo.LatestBlockHeight,
+		txs:     txs,
+		headers: headers,
+	}, nil
+}
+
+// run forwards events until the listener is stopped, reconnecting whenever the connection is lost
+func (el *EventListener) run(sub *subscription) {
+	defer close(el.done)
+	for {
+		err := el.forward(sub)
+		el.unsubscribe(sub)
+		if el.ctx.Err() != nil {
+			el.setState(StateStopped)
+			return
+		}
+		log.Printf("lost connection to pocket-core: %v\n", err)
+		el.setState(StateDisconnected)
+		if sub = el.reconnect(); sub == nil {
+			el.setState(StateStopped)
+			return
+		}
+	}
+}
+
+// reconnect retries to connect with an exponential backoff, returns nil once the listener is stopped
+func (el *EventListener) reconnect() *subscription {
+	backoff := minReconnectBackoff
+	for {
+		select {
+		case <-time.After(backoff):
+		case <-el.ctx.Done():
+			return nil
+		}
+		sub, err := el.connect()
+		if err == nil {
+			log.Println("reconnected to pocket-core")
+			return sub
+		}
+		log.Printf("could not reconnect to pocket-core: %v\n", err)
+		el.setState(StateDisconnected)
+		if backoff *= 2; backoff > maxReconnectBackoff {
+			backoff = maxReconnectBackoff
+		}
+	}
+}
+
+// forward passes events along until the connection looks dead or the listener is stopped
+func (el *EventListener) forward(sub *subscription) error {
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+	lagging := false
+	for {
+		select {
+		case evt := <-sub.txs:
+			select {
+			case el.txChan <- evt:
+			case <-el.ctx.Done():
+				return el.ctx.Err()
+			}
+		case evt := <-sub.headers:
+			if header, ok := evt.Data.(tmTypes.EventDataNewBlockHeader); ok {
+				sub.height = header.Header.Height
+			}
+			select {
+			case el.headerChan <- evt:
+			case <-el.ctx.Done():
+				return el.ctx.Err()
+			}
+		case <-ticker.C:
+			status, err := sub.status.Status()
+			if err != nil {
+				return errors.Wrap(err, "health check failed")
+			}
+			// the node commits blocks but no header reached us on two checks in a row, the websocket is dead
+			behind := status.SyncInfo.LatestBlockHeight > sub.height
+			if behind && lagging {
+				return errors.Errorf("no block header received since height %d, pocket-core is at height %d", sub.height, status.SyncInfo.LatestBlockHeight)
+			}
+			lagging = behind
+		case <-el.ctx.Done():
+			return el.ctx.Err()
+		}
+	}
+}
+
+// unsubscribe closes the websocket, errors are only logged as the connection may already be gone
+func (el *EventListener) unsubscribe(sub *subscription) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := sub.client.UnsubscribeAll(ctx, subscriber); err != nil {
+		log.Printf("could not unsubscribe from pocket-core: %v\n", err)
+	}
+	if err := sub.client.Stop(); err != nil {
+		log.Printf("could not stop the tendermint client: %v\n", err)
+	}
+}
+
+func subscribeToEvent(client client.Client, ctx context.Context, evt string) (<-chan coreTypes.ResultEvent, error) {
 	if !client.IsRunning() {
-		_ = client.Start()
+		if err := client.Start(); err != nil {
+			return nil, errors.Wrap(err, "could not start the tendermint client")
+		}
 	}
-	txChan, err := client.Subscribe(ctx, "helpers", tmTypes.QueryForEvent(evt).String())
+	txChan, err := client.Subscribe(ctx, subscriber, tmTypes.QueryForEvent(evt).String(), eventBufferSize)
 	if err != nil {
-		log.Fatal(err)
+		return nil, errors.Wrapf(err, "could not subscribe to %s", evt)
 	}
-	return txChan
+	return txChan, nil
 }
 
-func TMClient(port string) client.Client {
-	address := fmt.Sprintf("%s%s", defaultListenAddr, port)
+func TMClient(address string) client.Client {
 	fmt.Printf("opening client to %s\n", address)
 	client := client.NewHTTP(address, "/websocket")
 	return client
 }
 
-// Stop Listening
+// Stop Listening, waits until the connection to pocket-core is closed
 func (el *EventListener) Stop() {
-	err := el.client.UnsubscribeAll(el.ctx, "helpers")
-	if err != nil {
-		log.Fatal(err)
-	}
-	err = el.client.Stop()
-	if err != nil {
-		log.Fatal(err)
-	}
 	el.cancel()
-}
-func (el *EventListener) Reset(cfg *types.Config) *EventListener {
-	el.Stop()
-	return NewEventListener(cfg)
+	<-el.done
 }
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/pokt-network/pocket-runner/internal/types"
 	sdk "github.com/pokt-network/posmint/types"
@@ -32,7 +33,11 @@ func TestEventListener(t *testing.T) {
 	select {
 	case <-evtChan:
 		memCli, stopCli, evtChan = subscribeTo(t, tmTypes.EventNewBlockHeader)
-		eventListener = NewEventListener(cfg)
+		eventListener, err = NewEventListener(cfg)
+		if err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
 		tx, err = gov.UpgradeTx(memCodec(), memCli, kb, cb.GetAddress(), govTypes.Upgrade{
 			Height:  2,
 			Version: version,
@@ -57,3 +62,54 @@ func TestEventListener(t *testing.T) {
 		}
 	}
 }
+
+func TestEventListenerReconnect(t *testing.T) {
+	defaultInterval := healthCheckInterval
+	healthCheckInterval = 100 * time.Millisecond
+	defer func() { healthCheckInterval = defaultInterval }()
+	waitForHeader := func(t *testing.T, el *EventListener) {
+		select {
+		case <-el.HeaderChan:
+		case <-time.After(30 * time.Second):
+			t.Error("no block header received")
+			t.FailNow()
+		}
+	}
+	waitForState := func(t *testing.T, el *EventListener, want ConnectionState) {
+		for {
+			select {
+			case state := <-el.States():
+				if state == want {
+					return
+				}
+			case <-time.After(30 * time.Second):
+				t.Errorf("timed out waiting for state %s, listener is %s", want, el.State())
+				t.FailNow()
+			}
+		}
+	}
+
+	_, _, cleanup := NewInMemoryTendermintNode(t, oneValTwoNodeGenesisState())
+	cfg := &types.Config{Home: "", Name: "test-runnerd", Port: "36657", Readiness: types.ReadinessProbe{
+		Timeout:  time.Minute,
+		Interval: 100 * time.Millisecond,
+	}}
+	eventListener, err := NewEventListener(cfg)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer eventListener.Stop()
+	if eventListener.State() != StateConnected {
+		t.Errorf("expected listener to be connected, it is %s", eventListener.State())
+	}
+	waitForHeader(t, eventListener)
+	// the node goes away, the listener must notice and keep trying
+	cleanup()
+	waitForState(t, eventListener, StateDisconnected)
+	// the node is back, the listener must resubscribe and deliver headers on the same channel
+	_, _, cleanup = NewInMemoryTendermintNode(t, oneValTwoNodeGenesisState())
+	defer cleanup()
+	waitForState(t, eventListener, StateConnected)
+	waitForHeader(t, eventListener)
+}
@@ -0,0 +1,243 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pokt-network/pocket-runner/internal/types"
+)
+
+// partialExtension is appended to a file while it is being downloaded
+const partialExtension = ".partial"
+
+var (
+	// progressInterval is how often the progress of a download is logged
+	progressInterval = 10 * time.Second
+	// maxResumes bounds how many times an interrupted download is resumed before giving up
+	maxResumes = 3
+)
+
+var (
+	// ErrDownloadStalled occurs when a download receives no data for the idle timeout
+	ErrDownloadStalled = errors.New("download stalled")
+	// ErrDownloadTimeout occurs when a download does not complete within its timeout
+	ErrDownloadTimeout = errors.New("download timed out")
+	// ErrIncompleteDownload occurs when the server closes the connection before sending the whole file
+	ErrIncompleteDownload = errors.New("incomplete download")
+)
+
+// HTTPError is returned when the server answers a download with an unexpected status
+type HTTPError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("GET %s: %s", e.URL, e.Status)
+}
+
+// DownloadOptions bounds a download
+type DownloadOptions struct {
+	// Timeout bounds the whole download, resumes included. Zero means no limit.
+	Timeout time.Duration
+	// IdleTimeout aborts the download when no data was received for this long. Zero means no limit.
+	IdleTimeout time.Duration
+}
+
+// downloadOptions returns the options of a download from mirror
+func downloadOptions(cfg *types.Config, mirror types.Mirror) DownloadOptions {
+	return DownloadOptions{Timeout: mirror.WithDefaults().Timeout, IdleTimeout: cfg.Download.WithDefaults().IdleTimeout}
+}
+
+// Download writes the content at link to file. The content is streamed to <file>.partial, which is
+// resumed with a Range request if it is left over from an interrupted download, and renamed to file once complete.
+// Interrupted transfers are resumed up to maxResumes times, while any status but 200 or 206 is returned as an *HTTPError.
+func Download(ctx context.Context, link, file string, opts DownloadOptions) error {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	partial := file + partialExtension
+	for resumes := 0; ; resumes++ {
+		err := fetch(ctx, link, partial, opts.IdleTimeout)
+		if err == nil {
+			break
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			return errors.Wrapf(ErrDownloadTimeout, "GET %s: after %s", link, opts.Timeout)
+		}
+		if ctx.Err() != nil {
+			return errors.Wrapf(ctx.Err(), "GET %s", link)
+		}
+		if _, ok := errors.Cause(err).(*HTTPError); ok || resumes >= maxResumes {
+			return err
+		}
+		log.Printf("resuming download of %s: %v\n", link, err)
+	}
+	return errors.Wrapf(os.Rename(partial, file), "cannot install %s", file)
+}
+
+// fetch appends the content at link missing from partial
+func fetch(ctx context.Context, link, partial string, idle time.Duration) error {
+	var offset int64
+	if fi, err := os.Stat(partial); err == nil {
+		offset = fi.Size()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return errors.Wrapf(err, "invalid url %s", link)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	// a mirror that stops answering, before or after sending the headers, is abandoned after the idle timeout
+	timer := newIdleTimer(idle, cancel)
+	defer timer.stop()
+	resp, err := http.DefaultClient.Do(req)
+	if timer.fired() {
+		return errors.Wrapf(ErrDownloadStalled, "GET %s: no response for %s", link, idle)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "cannot download %s", link)
+	}
+	defer resp.Body.Close()
+
+	size, flag := int64(-1), os.O_WRONLY|os.O_CREATE|os.O_APPEND
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// the server ignored the range, or there was nothing to resume
+		if offset > 0 {
+			log.Printf("%s cannot be resumed, downloading it again\n", link)
+		}
+		offset, size, flag = 0, resp.ContentLength, os.O_WRONLY|os.O_CREATE|os.O_TRUNC
+	case http.StatusPartialContent:
+		start, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil || start != offset {
+			return errors.Errorf("GET %s: unexpected Content-Range %q for offset %d", link, resp.Header.Get("Content-Range"), offset)
+		}
+		size = total
+		log.Printf("resuming %s at %d bytes\n", link, offset)
+	case http.StatusRequestedRangeNotSatisfiable:
+		// the partial file does not belong to this artifact, the next attempt starts over
+		os.Remove(partial)
+		return errors.Errorf("GET %s: %s for offset %d", link, resp.Status, offset)
+	default:
+		return &HTTPError{URL: link, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	out, err := os.OpenFile(partial, flag, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "cannot create %s", partial)
+	}
+	defer out.Close()
+
+	body := &progressReader{r: resp.Body, link: link, read: offset, size: size, last: time.Now(), timer: timer}
+	n, err := io.Copy(out, body)
+	if timer.fired() {
+		return errors.Wrapf(ErrDownloadStalled, "GET %s: no data for %s", link, idle)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "cannot download %s", link)
+	}
+	if size >= 0 && offset+n != size {
+		return errors.Wrapf(ErrIncompleteDownload, "GET %s: expected %d bytes, got %d", link, size, offset+n)
+	}
+	return errors.Wrapf(out.Sync(), "cannot write %s", partial)
+}
+
+// parseContentRange returns the first byte and the total size of "bytes <start>-<end>/<total>", total is -1 if unknown
+func parseContentRange(s string) (start, total int64, err error) {
+	s = strings.TrimPrefix(s, "bytes ")
+	i, j := strings.Index(s, "-"), strings.Index(s, "/")
+	if i < 0 || j < i {
+		return 0, 0, errors.Errorf("invalid Content-Range %q", s)
+	}
+	if start, err = strconv.ParseInt(s[:i], 10, 64); err != nil {
+		return 0, 0, err
+	}
+	if s[j+1:] == "*" {
+		return start, -1, nil
+	}
+	total, err = strconv.ParseInt(s[j+1:], 10, 64)
+	return start, total, err
+}
+
+// progressReader logs the progress of a download and resets the idle timer whenever data arrives
+type progressReader struct {
+	r     io.Reader
+	link  string
+	read  int64
+	size  int64
+	last  time.Time
+	timer *idleTimer
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.timer.reset()
+	}
+	p.read += int64(n)
+	if time.Since(p.last) >= progressInterval {
+		p.last = time.Now()
+		if p.size > 0 {
+			log.Printf("downloading %s: %d/%d bytes (%d%%)\n", p.link, p.read, p.size, p.read*100/p.size)
+		} else {
+			log.Printf("downloading %s: %d bytes\n", p.link, p.read)
+		}
+	}
+	return n, err
+}
+
+// idleTimer cancels a download that made no progress for its timeout, it never fires if the timeout is zero
+type idleTimer struct {
+	idle  time.Duration
+	timer *time.Timer
+
+	mu      sync.Mutex
+	stalled bool
+}
+
+func newIdleTimer(idle time.Duration, cancel context.CancelFunc) *idleTimer {
+	t := &idleTimer{idle: idle}
+	if idle > 0 {
+		t.timer = time.AfterFunc(idle, func() {
+			t.mu.Lock()
+			t.stalled = true
+			t.mu.Unlock()
+			cancel()
+		})
+	}
+	return t
+}
+
+func (t *idleTimer) reset() {
+	if t.timer != nil {
+		t.timer.Reset(t.idle)
+	}
+}
+
+func (t *idleTimer) stop() {
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+}
+
+func (t *idleTimer) fired() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stalled
+}
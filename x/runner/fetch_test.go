@@ -0,0 +1,169 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// testContent is large enough to be sent in several reads
+var testContent = bytes.Repeat([]byte("pocket-core release "), 4096)
+
+func TestDownloadResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "download")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		mu       sync.Mutex
+		requests []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Header.Get("Range"))
+		first := len(requests) == 1
+		mu.Unlock()
+		if first {
+			// drop the connection half way through the first transfer
+			w.Header().Set("Content-Length", strconv.Itoa(len(testContent)))
+			_, _ = w.Write(testContent[:len(testContent)/2])
+			return
+		}
+		http.ServeContent(w, r, "release.tar.gz", time.Time{}, bytes.NewReader(testContent))
+	}))
+	defer server.Close()
+
+	file := filepath.Join(dir, "release.tar.gz")
+	if err := Download(context.Background(), server.URL+"/release.tar.gz", file, DownloadOptions{Timeout: 5 * time.Second}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if bz, err := ioutil.ReadFile(file); err != nil || !bytes.Equal(bz, testContent) {
+		t.Errorf("unexpected content of %d bytes %v", len(bz), err)
+	}
+	if _, err := os.Stat(file + partialExtension); !os.IsNotExist(err) {
+		t.Errorf("expected the partial file to be renamed, got %v", err)
+	}
+	if len(requests) != 2 || requests[0] != "" || requests[1] != "bytes="+strconv.Itoa(len(testContent)/2)+"-" {
+		t.Errorf("expected the second request to resume the first one, got %q", requests)
+	}
+}
+
+func TestDownloadPartialFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "download")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+
+	cases := map[string]struct {
+		partial []byte
+		ranges  bool
+	}{
+		"resumed":            {partial: testContent[:1000], ranges: true},
+		"range not accepted": {partial: testContent[:1000], ranges: false},
+		"stale partial":      {partial: append(append([]byte{}, testContent...), "more"...), ranges: true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !tc.ranges {
+					r.Header.Del("Range")
+				}
+				http.ServeContent(w, r, "source.zip", time.Time{}, bytes.NewReader(testContent))
+			}))
+			defer server.Close()
+
+			file := filepath.Join(dir, name+".zip")
+			if err := ioutil.WriteFile(file+partialExtension, tc.partial, 0644); err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+			if err := Download(context.Background(), server.URL+"/source.zip", file, DownloadOptions{}); err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+			if bz, err := ioutil.ReadFile(file); err != nil || !bytes.Equal(bz, testContent) {
+				t.Errorf("unexpected content of %d bytes %v", len(bz), err)
+			}
+		})
+	}
+}
+
+func TestDownloadErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "download")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/unavailable":
+			http.Error(w, "maintenance", http.StatusServiceUnavailable)
+		case "/stalled":
+			w.Header().Set("Content-Length", strconv.Itoa(len(testContent)))
+			_, _ = w.Write(testContent[:100])
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		case "/slow":
+			for i := 0; i < len(testContent); i += 100 {
+				_, _ = w.Write(testContent[i : i+100])
+				w.(http.Flusher).Flush()
+				select {
+				case <-time.After(20 * time.Millisecond):
+				case <-r.Context().Done():
+					return
+				}
+			}
+		}
+	}))
+	defer server.Close()
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	cases := map[string]struct {
+		ctx    context.Context
+		path   string
+		opts   DownloadOptions
+		expect error
+	}{
+		"http error": {ctx: context.Background(), path: "/unavailable"},
+		"stalled":    {ctx: context.Background(), path: "/stalled", opts: DownloadOptions{IdleTimeout: 50 * time.Millisecond}, expect: ErrDownloadStalled},
+		"timeout":    {ctx: context.Background(), path: "/slow", opts: DownloadOptions{Timeout: 100 * time.Millisecond, IdleTimeout: time.Second}, expect: ErrDownloadTimeout},
+		"cancelled":  {ctx: cancelled, path: "/slow", expect: context.Canceled},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := Download(tc.ctx, server.URL+tc.path, filepath.Join(dir, name), tc.opts)
+			if tc.expect == nil {
+				httpErr, ok := errors.Cause(err).(*HTTPError)
+				if !ok || httpErr.StatusCode != http.StatusServiceUnavailable {
+					t.Errorf("expected an HTTPError, got %v", err)
+				}
+				return
+			}
+			if errors.Cause(err) != tc.expect {
+				t.Errorf("expected %v, got %v", tc.expect, err)
+			}
+			if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+				t.Errorf("no file must be installed, got %v", err)
+			}
+		})
+	}
+}
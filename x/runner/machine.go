@@ -0,0 +1,468 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/pokt-network/pocket-runner/internal/types"
+)
+
+// UpgradeStage is where the upgrade state machine stands
+type UpgradeStage int
+
+const (
+	// StageIdle no upgrade is scheduled
+	StageIdle UpgradeStage = iota
+	// StageScheduled the next upgrade is known, its binary was not looked for yet
+	StageScheduled
+	// StageAcquiring the binary of the next upgrade is not installed, it is fetched in the background
+	StageAcquiring
+	// StageReady the binary of the next upgrade is installed, the machine waits for its height
+	StageReady
+	// StageHalting pocket-core is being stopped to switch binaries
+	StageHalting
+	// StageSwitching the current binary is being switched, to the upgrade or back to the previous binary
+	StageSwitching
+	// StageVerifying the upgraded pocket-core is in the health window of the rollback policy
+	StageVerifying
+	// StageRunning the upgrade was applied, pocket-core runs its binary
+	StageRunning
+	// StageRolledBack the upgrade failed its health window, pocket-core runs the previous binary again
+	StageRolledBack
+)
+
+func (s UpgradeStage) String() string {
+	switch s {
+	case StageIdle:
+		return "idle"
+	case StageScheduled:
+		return "scheduled"
+	case StageAcquiring:
+		return "acquiring"
+	case StageReady:
+		return "ready"
+	case StageHalting:
+		return "halting"
+	case StageSwitching:
+		return "switching"
+	case StageVerifying:
+		return "verifying"
+	case StageRunning:
+		return "running"
+	case StageRolledBack:
+		return "rolled back"
+	}
+	return "unknown"
+}
+
+// transitionBufferSize is how many transitions are kept for a slow reader before they are dropped
+const transitionBufferSize = 64
+
+// Transition is a change of stage of the upgrade state machine
+type Transition struct {
+	From    UpgradeStage
+	To      UpgradeStage
+	Upgrade *types.UpgradeInfo
+	Reason  string
+}
+
+func (t Transition) String() string {
+	if t.Upgrade == nil {
+		return fmt.Sprintf("upgrade stage %s -> %s: %s", t.From, t.To, t.Reason)
+	}
+	return fmt.Sprintf("upgrade %s stage %s -> %s: %s", t.Upgrade.Name, t.From, t.To, t.Reason)
+}
+
+// machineEventKind tells what an event posted to the upgrade state machine is about
+type machineEventKind int
+
+const (
+	// eventScheduled an upgrade was scheduled on chain
+	eventScheduled machineEventKind = iota
+	// eventHeight pocket-core produced a block
+	eventHeight
+	// eventVerified the health window of an upgrade ended
+	eventVerified
+	// eventHalted pocket-core exited on its own right before the height of the next upgrade
+	eventHalted
+)
+
+// machineEvent drives the upgrade state machine
+type machineEvent struct {
+	kind    machineEventKind
+	upgrade *types.UpgradeInfo
+	height  int64
+	err     error
+}
+
+// UpgradeMachine applies the upgrades of a plan one stage at a time:
+// Idle -> Scheduled -> Acquiring -> Ready -> Halting -> Switching -> Verifying -> Running or RolledBack.
+// It only moves on the events it is handed, the upgrades scheduled on chain and the heights pocket-core reaches,
+// and a single goroutine handles them in order, so upgrades never race each other. Every transition is logged with its reason.
+type UpgradeMachine struct {
+	cfg         *types.Config
+	manager     *ProcessManager
+	restarts    *Restarter
+	prefetcher  *Prefetcher
+	state       *types.UpgradeState
+	plan        *types.UpgradePlan
+	events      chan machineEvent
+	transitions chan Transition
+	// height is the latest height of pocket-core, record the upgrade in its health window
+	height int64
+	record *types.UpgradeRecord
+	// mu guards stage, upgrade, the upgrade the stage is about, and the writes of height, Run is its only writer
+	mu      sync.Mutex
+	stage   UpgradeStage
+	upgrade *types.UpgradeInfo
+}
+
+// NewUpgradeMachine returns a machine applying the upgrades of plan to the pocket-core run by manager, it is Idle until Run
+func NewUpgradeMachine(cfg *types.Config, manager *ProcessManager, restarts *Restarter, prefetcher *Prefetcher, state *types.UpgradeState, plan *types.UpgradePlan) *UpgradeMachine {
+	return &UpgradeMachine{
+		cfg:         cfg,
+		manager:     manager,
+		restarts:    restarts,
+		prefetcher:  prefetcher,
+		state:       state,
+		plan:        plan,
+		events:      make(chan machineEvent),
+		transitions: make(chan Transition, transitionBufferSize),
+	}
+}
+
+// Stage returns the current stage and the upgrade it is about, nil when Idle
+func (m *UpgradeMachine) Stage() (UpgradeStage, *types.UpgradeInfo) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stage, m.upgrade
+}
+
+// Halt tells the machine pocket-core exited at the height of the next upgrade, see AtUpgradeHeight
+func (m *UpgradeMachine) Halt(ctx context.Context) error {
+	return m.post(ctx, machineEvent{kind: eventHalted})
+}
+
+// AtUpgradeHeight reports whether the next upgrade is at the height following the latest block of pocket-core.
+// Governance halts pocket-core at the upgrade height, before the block at that height is committed.
+func (m *UpgradeMachine) AtUpgradeHeight() bool {
+	m.mu.Lock()
+	height := m.height
+	m.mu.Unlock()
+	next := m.plan.Next()
+	return next != nil && height > 0 && next.Height == height+1 && next.Name != m.cfg.CurrentUpgrade()
+}
+
+// Upgrading reports whether an upgrade is being applied or pocket-core reached the height of the next one.
+// The upgrade relaunches pocket-core then, restarts must be left to it.
+func (m *UpgradeMachine) Upgrading() bool {
+	m.mu.Lock()
+	stage, height := m.stage, m.height
+	m.mu.Unlock()
+	switch stage {
+	case StageHalting, StageSwitching, StageVerifying:
+		return true
+	}
+	next := m.plan.Next()
+	return next != nil && height > 0 && next.Height <= height && next.Name != m.cfg.CurrentUpgrade()
+}
+
+// setHeight records height as the latest height of pocket-core
+func (m *UpgradeMachine) setHeight(height int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.height = height
+}
+
+// Transitions is where every transition is sent, they are dropped while the channel is full so nobody has to read it
+func (m *UpgradeMachine) Transitions() <-chan Transition {
+	return m.transitions
+}
+
+// Schedule hands an upgrade scheduled on chain to the machine
+func (m *UpgradeMachine) Schedule(ctx context.Context, upgrade *types.UpgradeInfo) error {
+	return m.post(ctx, machineEvent{kind: eventScheduled, upgrade: upgrade})
+}
+
+// Height tells the machine pocket-core produced the block at height
+func (m *UpgradeMachine) Height(ctx context.Context, height int64) error {
+	return m.post(ctx, machineEvent{kind: eventHeight, height: height})
+}
+
+// Run handles the events posted to the machine until ctx is done. It first resumes the health window of an upgrade
+// the runner was verifying when it stopped and applies the upgrades pocket-core is already past.
+// It returns an error when an upgrade cannot be applied or rolled back, nil once ctx is done.
+func (m *UpgradeMachine) Run(ctx context.Context) error {
+	// catch up on upgrades scheduled while the runner was offline
+	for _, pending := range m.plan.Upgrades() {
+		m.prefetcher.Prefetch(pending)
+	}
+	if record := m.state.Unverified(); record != nil && !m.cfg.Rollback.Disabled && record.Name == m.cfg.CurrentUpgrade() {
+		m.verify(ctx, record, "the runner restarted during the health window")
+	}
+	// the runner may start after an upgrade height, or miss it while pocket-core restarts
+	height, err := LatestHeight(m.cfg)
+	if err != nil {
+		log.Printf("could not check for upgrades past due: %v\n", err)
+	}
+	m.setHeight(height)
+	if err := m.advance(ctx); err != nil {
+		return err
+	}
+	for {
+		select {
+		case evt := <-m.events:
+			if err := m.handle(ctx, evt); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// post sends evt to Run, it gives up once ctx is done
+func (m *UpgradeMachine) post(ctx context.Context, evt machineEvent) error {
+	select {
+	case m.events <- evt:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// handle applies evt and moves the machine as far as it can go
+func (m *UpgradeMachine) handle(ctx context.Context, evt machineEvent) error {
+	switch evt.kind {
+	case eventScheduled:
+		m.schedule(evt.upgrade)
+	case eventHeight:
+		// pocket-core is producing blocks again, previous failures no longer count against the budget
+		m.restarts.Reset()
+		m.setHeight(evt.height)
+	case eventHalted:
+		// the block at the upgrade height never arrives from the halted binary, the upgrade is due now
+		if next := m.plan.Next(); next != nil && next.Height == m.height+1 {
+			log.Printf("pocket-core halted at height %d for upgrade %s\n", next.Height, next.Name)
+			m.setHeight(next.Height)
+		}
+	case eventVerified:
+		if stage, _ := m.Stage(); stage != StageVerifying || m.record == nil || m.record.Name != evt.upgrade.Name {
+			return nil
+		}
+		if evt.err != nil {
+			if ctx.Err() != nil {
+				return nil // the runner is stopping, the health window is resumed on the next start
+			}
+			if err := m.rollback(ctx, evt.err); err != nil {
+				return err
+			}
+			break
+		}
+		if err := m.state.MarkVerified(m.record.Name); err != nil {
+			log.Printf("could not save the upgrade state: %v\n", err)
+		}
+		m.record = nil
+		m.transition(StageRunning, evt.upgrade, fmt.Sprintf("pocket-core produced a block past height %d", evt.upgrade.Height))
+	}
+	return m.advance(ctx)
+}
+
+// schedule records upgrade in place of every pending upgrade and starts fetching its binary.
+// Governance keeps a single upgrade, each upgrade tx overwrites the upgrade scheduled before.
+func (m *UpgradeMachine) schedule(upgrade *types.UpgradeInfo) {
+	if err := m.state.Schedule(upgrade); err != nil {
+		log.Printf("could not save the upgrade state: %v\n", err)
+	}
+	for _, replaced := range m.plan.Schedule(upgrade) {
+		log.Printf("upgrade %s at height %d was replaced by %s at height %d\n", replaced.Name, replaced.Height, upgrade.Name, upgrade.Height)
+		if replaced.Name != upgrade.Name {
+			m.prefetcher.Cancel(replaced.Name)
+		}
+	}
+	m.prefetcher.Prefetch(upgrade)
+}
+
+// advance moves the machine towards the next upgrade of the plan, and applies it once it is due.
+// The next upgrade waits for the health window of the previous one.
+func (m *UpgradeMachine) advance(ctx context.Context) error {
+	stage, _ := m.Stage()
+	if stage == StageVerifying {
+		return nil
+	}
+	due := DueUpgrade(m.cfg, m.state, m.plan, m.height)
+	next := m.plan.Next()
+	if next == nil {
+		if waiting(stage) {
+			m.transition(StageIdle, nil, "no upgrade is scheduled")
+		}
+		return nil
+	}
+	m.track(next)
+	if due == nil {
+		return nil
+	}
+	// the binary is checked before stopping pocket-core, a missing binary leaves the node running the old one
+	if err := m.prefetcher.Acquire(ctx, due); err != nil {
+		return err
+	}
+	if stage, _ := m.Stage(); stage == StageAcquiring {
+		m.transition(StageReady, due, "the binary was acquired at the upgrade height")
+	}
+	if err := m.apply(ctx, due); err != nil {
+		return err
+	}
+	// further upgrades may be due already
+	return m.advance(ctx)
+}
+
+// track moves the machine to the stage next is at before its height
+func (m *UpgradeMachine) track(next *types.UpgradeInfo) {
+	stage, current := m.Stage()
+	if !waiting(stage) || current.Name != next.Name || current.Height != next.Height {
+		m.transition(StageScheduled, next, fmt.Sprintf("next upgrade, at height %d", next.Height))
+		stage = StageScheduled
+	}
+	if stage == StageReady {
+		return
+	}
+	if err := types.CheckBinary(m.cfg.UpgradeBin(next.Name)); err == nil {
+		m.transition(StageReady, next, "the binary is installed")
+	} else if stage == StageScheduled {
+		m.transition(StageAcquiring, next, "the binary is not installed yet")
+	}
+}
+
+// apply stops pocket-core, switches binaries and relaunches it, then starts the health window if rollback is enabled
+func (m *UpgradeMachine) apply(ctx context.Context, upgrade *types.UpgradeInfo) error {
+	reason := fmt.Sprintf("height %d reached", upgrade.Height)
+	if m.height > upgrade.Height {
+		reason = fmt.Sprintf("pocket-core is at height %d, past the upgrade height %d", m.height, upgrade.Height)
+	}
+	m.transition(StageHalting, upgrade, reason)
+	// PROCESS MUST DIE BEFORE UPGRADING; cfg.Current is a symlink otherwise bugs might happen.
+	// The manager is held from the halt to the relaunch, a restart of pocket-core cannot slip in between.
+	var point types.RollbackPoint
+	_, err := m.restarts.Switch(ctx, m.manager, func() error {
+		m.transition(StageSwitching, upgrade, "pocket-core stopped")
+		var err error
+		if point, err = Switch(m.cfg, upgrade); err != nil {
+			return err
+		}
+		point.Height = m.height
+		log.Printf("Upgrade to %s performed successfully!!\n", upgrade.Name)
+		m.plan.Done(upgrade)
+		if m.cfg.Rollback.Disabled {
+			err = m.state.MarkApplied(upgrade)
+		} else {
+			err = m.state.MarkSwitched(upgrade, point)
+		}
+		if err != nil {
+			log.Printf("could not save the upgrade state: %v\n", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if m.cfg.Rollback.Disabled {
+		m.transition(StageRunning, upgrade, "pocket-core relaunched, rollback is disabled")
+		return nil
+	}
+	m.verify(ctx, &types.UpgradeRecord{
+		Name:     upgrade.Name,
+		Version:  upgrade.Version,
+		Height:   upgrade.Height,
+		TxHash:   upgrade.TxHash,
+		Checksum: upgrade.Checksum,
+		Rollback: &point,
+	}, "pocket-core relaunched")
+	return nil
+}
+
+// verify starts the health window of record, its outcome is posted back to Run
+func (m *UpgradeMachine) verify(ctx context.Context, record *types.UpgradeRecord, reason string) {
+	m.record = record
+	upgrade := record.Info()
+	m.transition(StageVerifying, upgrade, fmt.Sprintf("%s, health window of %s", reason, m.cfg.Rollback.WithDefaults().Window))
+	var switched int64
+	if record.Rollback != nil {
+		switched = record.Rollback.Height
+	}
+	go func() {
+		err := m.manager.Verify(ctx, upgrade, switched)
+		_ = m.post(ctx, machineEvent{kind: eventVerified, upgrade: upgrade, err: err})
+	}()
+}
+
+// rollback restores and relaunches the binary replaced by the upgrade that failed its health window
+func (m *UpgradeMachine) rollback(ctx context.Context, failure error) error {
+	record := m.record
+	m.record = nil
+	upgrade := record.Info()
+	log.Printf("UPGRADE %s FAILED: %v, rolling back\n", record.Name, failure)
+	m.transition(StageHalting, upgrade, fmt.Sprintf("the health window failed: %v", failure))
+	_, err := m.restarts.Switch(ctx, m.manager, func() error {
+		m.transition(StageSwitching, upgrade, "pocket-core stopped, restoring the previous binary")
+		if err := Rollback(m.cfg, *record); err != nil {
+			return err
+		}
+		if err := m.state.MarkRolledBack(record.Name, failure); err != nil {
+			log.Printf("could not save the upgrade state: %v\n", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("rolled back upgrade %s, pocket-core is running the previous binary again\n", record.Name)
+	m.transition(StageRolledBack, upgrade, failure.Error())
+	return nil
+}
+
+// transition moves the machine to stage, logs it and publishes it
+func (m *UpgradeMachine) transition(stage UpgradeStage, upgrade *types.UpgradeInfo, reason string) {
+	m.mu.Lock()
+	t := Transition{From: m.stage, To: stage, Upgrade: upgrade, Reason: reason}
+	m.stage = stage
+	m.upgrade = upgrade
+	m.mu.Unlock()
+	log.Println(t)
+	select {
+	case m.transitions <- t:
+	default:
+	}
+}
+
+// waiting reports whether stage waits for the height of the next upgrade
+func waiting(stage UpgradeStage) bool {
+	return stage == StageScheduled || stage == StageAcquiring || stage == StageReady
+}
+
+// DueUpgrade returns the next upgrade of plan if pocket-core reached its height, nil otherwise.
+// Upgrades that are already running are dropped from the plan instead of being applied twice.
+func DueUpgrade(cfg *types.Config, state *types.UpgradeState, plan *types.UpgradePlan, height int64) *types.UpgradeInfo {
+	if height <= 0 {
+		return nil
+	}
+	current := cfg.CurrentUpgrade()
+	for next := plan.Next(); next != nil; next = plan.Next() {
+		if next.Height > height {
+			return nil
+		}
+		if next.Name != current {
+			if next.Height < height {
+				log.Printf("pocket-core is at height %d, past the height %d of upgrade %s, switching now\n", height, next.Height, next.Name)
+			}
+			return next
+		}
+		log.Printf("upgrade %s is already running\n", next.Name)
+		plan.Done(next)
+		if err := state.MarkApplied(next); err != nil {
+			log.Printf("could not save the upgrade state: %v\n", err)
+		}
+	}
+	return nil
+}
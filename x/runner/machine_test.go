@@ -0,0 +1,326 @@
+package runner
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pokt-network/pocket-runner/internal/types"
+)
+
+// sleeperScript stands in for pocket-core, it runs until it is stopped
+const sleeperScript = "#!/bin/sh\ntrap 'exit 0' TERM\nwhile true; do sleep 0.1; done\n"
+
+// newTestMachine returns a machine running sleeperScript as pocket-core, upgrades are verified against a status server at height
+func newTestMachine(t *testing.T, height int64) (*UpgradeMachine, func()) {
+	home, err := copyTestData("validate")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	server, port := newStatusServer(t, 0, height)
+	cfg := &types.Config{
+		Home:      home,
+		Name:      "sleeper",
+		Port:      port,
+		Backup:    types.BackupPolicy{Skip: true},
+		Shutdown:  types.ShutdownPolicy{Grace: 5 * time.Second},
+		Readiness: types.ReadinessProbe{Interval: 10 * time.Millisecond},
+		Rollback:  types.RollbackPolicy{Window: 300 * time.Millisecond},
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.GenesisBin()), 0755); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err := ioutil.WriteFile(cfg.GenesisBin(), []byte(sleeperScript), 0755); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	state, err := types.LoadUpgradeState(cfg.UpgradeStateFile())
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	manager := NewProcessManager(cfg, nil, ioutil.Discard, ioutil.Discard, nil)
+	if _, err := manager.Start(); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	machine := NewUpgradeMachine(cfg, manager, NewRestarter(types.DefaultRestartPolicy()), NewPrefetcher(ctx, cfg), state, types.NewUpgradePlan())
+	return machine, func() {
+		cancel()
+		_ = manager.Stop()
+		server.Close()
+		os.RemoveAll(home)
+	}
+}
+
+// stages returns the stages the machine went through since the last call
+func stages(machine *UpgradeMachine) (stages []UpgradeStage) {
+	for {
+		select {
+		case t := <-machine.Transitions():
+			stages = append(stages, t.To)
+		default:
+			return stages
+		}
+	}
+}
+
+// verified waits for the outcome of the health window and hands it to the machine
+func verified(t *testing.T, ctx context.Context, machine *UpgradeMachine) error {
+	select {
+	case evt := <-machine.events:
+		return machine.handle(ctx, evt)
+	case <-time.After(5 * time.Second):
+		t.Error("timed out waiting for the health window")
+		t.FailNow()
+	}
+	return nil
+}
+
+func TestUpgradeMachine(t *testing.T) {
+	ctx := context.Background()
+	upgrade := &types.UpgradeInfo{Name: "RC-0.2.0", Version: "RC-0.2.0", Height: 10}
+	cases := []struct {
+		name   string
+		height int64
+		// expected are the stages up to the upgrade height, then the ones after it
+		expected []UpgradeStage
+		applied  []UpgradeStage
+		current  string
+	}{
+		{
+			name:     "healthy upgrade",
+			height:   11,
+			expected: []UpgradeStage{StageScheduled, StageReady},
+			applied:  []UpgradeStage{StageHalting, StageSwitching, StageVerifying, StageRunning},
+			current:  upgrade.Name,
+		},
+		{
+			name:     "stalled upgrade is rolled back",
+			height:   10,
+			expected: []UpgradeStage{StageScheduled, StageReady},
+			applied:  []UpgradeStage{StageHalting, StageSwitching, StageVerifying, StageHalting, StageSwitching, StageRolledBack},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			machine, cleanup := newTestMachine(t, tc.height)
+			defer cleanup()
+			if err := os.MkdirAll(filepath.Dir(machine.cfg.UpgradeBin(upgrade.Name)), 0755); err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+			if err := ioutil.WriteFile(machine.cfg.UpgradeBin(upgrade.Name), []byte(sleeperScript), 0755); err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+			if err := machine.handle(ctx, machineEvent{kind: eventScheduled, upgrade: upgrade}); err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+			if err := machine.handle(ctx, machineEvent{kind: eventHeight, height: upgrade.Height - 1}); err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+			if got := stages(machine); !equalStages(got, tc.expected) {
+				t.Errorf("expected stages %v before the upgrade height, got %v", tc.expected, got)
+			}
+			first := machine.manager.Current()
+			if err := machine.handle(ctx, machineEvent{kind: eventHeight, height: upgrade.Height}); err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+			if machine.manager.Current() == first || !machine.manager.Running() {
+				t.Error("expected pocket-core to be relaunched on the upgraded binary")
+			}
+			if err := verified(t, ctx, machine); err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+			if got := stages(machine); !equalStages(got, tc.applied) {
+				t.Errorf("expected stages %v at the upgrade height, got %v", tc.applied, got)
+			}
+			if current := machine.cfg.CurrentUpgrade(); current != tc.current {
+				t.Errorf("expected the current upgrade to be %q, got %q", tc.current, current)
+			}
+			if machine.plan.Len() != 0 || len(machine.state.Applied) != 1 {
+				t.Errorf("expected %s to be applied, plan %+v state %+v", upgrade.Name, machine.plan.Upgrades(), machine.state)
+			}
+		})
+	}
+}
+
+func TestUpgradeMachineMissingBinary(t *testing.T) {
+	ctx := context.Background()
+	machine, cleanup := newTestMachine(t, 11)
+	defer cleanup()
+	upgrade := &types.UpgradeInfo{Name: "RC-0.2.0", Version: "RC-0.2.0", Height: 10}
+	if err := os.RemoveAll(machine.cfg.UpgradeDir(upgrade.Name)); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err := machine.handle(ctx, machineEvent{kind: eventScheduled, upgrade: upgrade}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if stage, current := machine.Stage(); stage != StageAcquiring || current.Name != upgrade.Name {
+		t.Errorf("expected %s to be acquiring, got %s", upgrade.Name, stage)
+	}
+	first := machine.manager.Current()
+	err := machine.handle(ctx, machineEvent{kind: eventHeight, height: upgrade.Height})
+	if errors.Cause(err) != ErrBinaryMissing {
+		t.Errorf("expected ErrBinaryMissing, got %v", err)
+	}
+	// pocket-core is left running the old binary
+	if stage, _ := machine.Stage(); stage != StageAcquiring || machine.manager.Current() != first || !machine.manager.Running() {
+		t.Errorf("expected pocket-core to keep running, stage %s", stage)
+	}
+}
+
+func TestUpgradeMachineReplaced(t *testing.T) {
+	ctx := context.Background()
+	machine, cleanup := newTestMachine(t, 5)
+	defer cleanup()
+	first := &types.UpgradeInfo{Name: "RC-0.2.0", Version: "RC-0.2.0", Height: 10}
+	second := &types.UpgradeInfo{Name: "RC-0.2.1", Version: "RC-0.2.1", Height: 20}
+	for _, upgrade := range []*types.UpgradeInfo{first, second} {
+		if err := machine.handle(ctx, machineEvent{kind: eventScheduled, upgrade: upgrade}); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+	}
+	// governance keeps a single upgrade, the upgrade tx for second overwrote first
+	if stage, current := machine.Stage(); current == nil || current.Name != second.Name || !waiting(stage) {
+		t.Errorf("expected the machine to wait for %s, got %s %+v", second.Name, stage, current)
+	}
+	if upgrades := machine.plan.Upgrades(); len(upgrades) != 1 || upgrades[0] != second {
+		t.Errorf("expected %s alone to be queued, got %+v", second.Name, upgrades)
+	}
+	if pending := machine.state.PendingUpgrades(); len(pending) != 1 || pending[0].Name != second.Name {
+		t.Errorf("expected %s alone to be pending, got %+v", second.Name, pending)
+	}
+	// first is no longer due at its height
+	if err := machine.handle(ctx, machineEvent{kind: eventHeight, height: first.Height}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if stage, current := machine.Stage(); current == nil || current.Name != second.Name || !waiting(stage) {
+		t.Errorf("expected the machine to keep waiting for %s, got %s %+v", second.Name, stage, current)
+	}
+}
+
+func TestUpgradeMachineRun(t *testing.T) {
+	machine, cleanup := newTestMachine(t, 5)
+	defer cleanup()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- machine.Run(ctx)
+	}()
+	if err := machine.Height(ctx, 6); err != nil {
+		t.Error(err)
+	}
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Run did not return once its context was done")
+	}
+	// nothing reads the events anymore, posting must not block
+	if err := machine.Height(ctx, 7); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestDueUpgrade(t *testing.T) {
+	home, err := copyTestData("validate")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(home)
+	cfg := &types.Config{Home: home, Name: "test-runnerd"}
+	state, err := types.LoadUpgradeState(cfg.UpgradeStateFile())
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err := cfg.SetCurrentUpgrade("RC-0.2.0"); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	running := &types.UpgradeInfo{Name: "RC-0.2.0", Version: "RC-0.2.0", Height: 5}
+	next := &types.UpgradeInfo{Name: "RC-0.2.1", Version: "RC-0.2.1", Height: 10}
+	plan := types.NewUpgradePlan(running)
+
+	if due := DueUpgrade(cfg, state, plan, 0); due != nil {
+		t.Errorf("no upgrade is due before the height is known, got %+v", due)
+	}
+	// the running upgrade is dropped instead of being applied again
+	if due := DueUpgrade(cfg, state, plan, 7); due != nil {
+		t.Errorf("no upgrade is due at height 7, got %+v", due)
+	}
+	if plan.Len() != 0 || len(state.Applied) != 1 || state.Applied[0].Name != running.Name {
+		t.Errorf("expected %s to be recorded as applied, plan %+v state %+v", running.Name, plan.Upgrades(), state)
+	}
+	plan.Schedule(next)
+	if due := DueUpgrade(cfg, state, plan, 10); due != next {
+		t.Errorf("expected %s to be due at its height, got %+v", next.Name, due)
+	}
+	// the height was missed
+	if due := DueUpgrade(cfg, state, plan, 12); due != next {
+		t.Errorf("expected %s to be due past its height, got %+v", next.Name, due)
+	}
+}
+
+func equalStages(a, b []UpgradeStage) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestUpgradeMachineUpgrading(t *testing.T) {
+	ctx := context.Background()
+	machine, cleanup := newTestMachine(t, 5)
+	defer cleanup()
+	upgrade := &types.UpgradeInfo{Name: "RC-0.2.0", Version: "RC-0.2.0", Height: 10}
+	if err := machine.handle(ctx, machineEvent{kind: eventScheduled, upgrade: upgrade}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err := machine.handle(ctx, machineEvent{kind: eventHeight, height: 5}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if machine.Upgrading() {
+		t.Error("no upgrade is in progress before its height")
+	}
+	// pocket-core reached the height while the binary is still being acquired
+	machine.setHeight(upgrade.Height)
+	if !machine.Upgrading() {
+		t.Error("expected the due upgrade to hold restarts back")
+	}
+	machine.setHeight(5)
+	for _, stage := range []UpgradeStage{StageHalting, StageSwitching, StageVerifying} {
+		machine.transition(stage, upgrade, "test")
+		if !machine.Upgrading() {
+			t.Errorf("expected the %s stage to hold restarts back", stage)
+		}
+	}
+}
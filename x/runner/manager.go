@@ -0,0 +1,193 @@
+package runner
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/pokt-network/pocket-runner/internal/types"
+)
+
+var (
+	// ErrAlreadyRunning occurs when pocket-core is started while the process of the manager is still running
+	ErrAlreadyRunning = errors.New("pocket-core is already running")
+	// ErrNotRunning occurs when the process of the manager is signalled or waited for before it was started
+	ErrNotRunning = errors.New("pocket-core is not running")
+	// ErrManagerClosed occurs when pocket-core is started by a manager that was closed
+	ErrManagerClosed = errors.New("process manager is closed")
+)
+
+// ProcessManager exclusively owns the pocket-core child process: starting, signalling, stopping and reaping
+// it all go through the manager, so there is never more than one pocket-core running on the data dir.
+// Start, Stop, Restart and Switch are serialized, a process is only started once the previous one was reaped.
+type ProcessManager struct {
+	cfg        *types.Config
+	args       []string
+	stdout     io.Writer
+	stderr     io.Writer
+	passphrase *Passphrase
+	supervisor *Supervisor
+	// ops serializes the operations changing the process, mu guards cmd
+	ops    sync.Mutex
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	closed bool
+}
+
+// NewProcessManager returns a manager launching the current binary of cfg with args, nothing is started yet
+func NewProcessManager(cfg *types.Config, args []string, stdout, stderr io.Writer, passphrase *Passphrase) *ProcessManager {
+	return &ProcessManager{
+		cfg:        cfg,
+		args:       args,
+		stdout:     stdout,
+		stderr:     stderr,
+		passphrase: passphrase,
+		supervisor: NewSupervisor(),
+	}
+}
+
+// Exits is where an ExitEvent is sent every time a process of the manager terminates
+func (m *ProcessManager) Exits() <-chan ExitEvent {
+	return m.supervisor.Exits()
+}
+
+// Current returns the process started last, nil if none was started
+func (m *ProcessManager) Current() *exec.Cmd {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cmd
+}
+
+// Running reports whether the process started last was not reaped yet
+func (m *ProcessManager) Running() bool {
+	cmd := m.Current()
+	return cmd != nil && !reaped(m.supervisor.Done(cmd))
+}
+
+// Start launches the current binary, fails with ErrAlreadyRunning unless the previous process was reaped
+func (m *ProcessManager) Start() (*exec.Cmd, error) {
+	m.ops.Lock()
+	defer m.ops.Unlock()
+	return m.start()
+}
+
+// Stop gracefully stops the running process following the shutdown policy, see Supervisor.Stop.
+// Its exit is reported as expected. Stopping a manager with no running process is a no-op.
+func (m *ProcessManager) Stop() error {
+	m.ops.Lock()
+	defer m.ops.Unlock()
+	return m.stop()
+}
+
+// Close stops the running process like Stop, every later Start fails with ErrManagerClosed.
+// Exits are no longer sent once the process was stopped.
+func (m *ProcessManager) Close() error {
+	m.ops.Lock()
+	defer m.ops.Unlock()
+	m.mu.Lock()
+	m.closed = true
+	m.mu.Unlock()
+	err := m.stop()
+	m.supervisor.Close()
+	return err
+}
+
+// Restart stops the running process, if any, and launches the current binary again
+func (m *ProcessManager) Restart() (*exec.Cmd, error) {
+	m.ops.Lock()
+	defer m.ops.Unlock()
+	if err := m.stop(); err != nil {
+		return nil, err
+	}
+	return m.start()
+}
+
+// Switch stops the running process, calls change while no process runs and launches the current binary again.
+// Start and Restart wait for the whole sequence, so pocket-core is never relaunched while its binary or data change.
+// pocket-core is left stopped if change fails.
+func (m *ProcessManager) Switch(change func() error) (*exec.Cmd, error) {
+	m.ops.Lock()
+	defer m.ops.Unlock()
+	if err := m.stop(); err != nil {
+		return nil, err
+	}
+	if err := change(); err != nil {
+		return nil, err
+	}
+	return m.start()
+}
+
+// Signal sends sig to the running process
+func (m *ProcessManager) Signal(sig os.Signal) error {
+	cmd := m.Current()
+	if cmd == nil {
+		return ErrNotRunning
+	}
+	err := m.supervisor.Signal(cmd, sig)
+	if err == errReaped {
+		return ErrNotRunning
+	}
+	return errors.Wrapf(err, "cannot send %s to pocket-core (pid %d)", sig, cmd.Process.Pid)
+}
+
+// Wait blocks until the process started last was reaped and returns how it exited.
+// The event does not tell whether the exit was expected, Exits does.
+func (m *ProcessManager) Wait() (ExitEvent, error) {
+	cmd := m.Current()
+	if cmd == nil {
+		return ExitEvent{}, ErrNotRunning
+	}
+	<-m.supervisor.Done(cmd)
+	if cmd.ProcessState == nil {
+		// cmd.Wait failed before the process was reaped
+		err := m.supervisor.Err(cmd)
+		return exitEvent(cmd, err), errors.Wrapf(err, "cannot wait for pocket-core (pid %d)", cmd.Process.Pid)
+	}
+	var err error
+	if !cmd.ProcessState.Success() {
+		err = &exec.ExitError{ProcessState: cmd.ProcessState}
+	}
+	return exitEvent(cmd, err), nil
+}
+
+// Verify runs VerifyUpgrade on the running process, see VerifyUpgrade
+func (m *ProcessManager) Verify(ctx context.Context, upgrade *types.UpgradeInfo, switched int64) error {
+	cmd := m.Current()
+	if cmd == nil {
+		return ErrNotRunning
+	}
+	return VerifyUpgrade(ctx, m.cfg, m.supervisor, cmd, upgrade, switched)
+}
+
+func (m *ProcessManager) start() (*exec.Cmd, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, ErrManagerClosed
+	}
+	if m.cmd != nil && !reaped(m.supervisor.Done(m.cmd)) {
+		return nil, errors.Wrapf(ErrAlreadyRunning, "pid %d", m.cmd.Process.Pid)
+	}
+	cmd, err := LaunchWithPassphrase(m.cfg, m.args, m.stdout, m.stderr, m.passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if m.cmd != nil {
+		// the replaced process was reaped, nothing asks the supervisor about it anymore
+		m.supervisor.Forget(m.cmd)
+	}
+	m.cmd = cmd
+	m.supervisor.Watch(cmd)
+	return cmd, nil
+}
+
+func (m *ProcessManager) stop() error {
+	cmd := m.Current()
+	if cmd == nil || reaped(m.supervisor.Done(cmd)) {
+		return nil
+	}
+	return m.supervisor.Stop(cmd, m.cfg.Shutdown)
+}
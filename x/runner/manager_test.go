@@ -0,0 +1,188 @@
+package runner
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pokt-network/pocket-runner/internal/types"
+)
+
+func TestProcessManager(t *testing.T) {
+	home, err := copyTestData("validate")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(home)
+	cfg := &types.Config{Home: home, Name: "sleeper", Shutdown: types.ShutdownPolicy{Grace: 5 * time.Second}}
+	if err := os.MkdirAll(filepath.Dir(cfg.GenesisBin()), 0755); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	script := "#!/bin/sh\ntrap 'exit 0' TERM\ntrap 'exit 7' USR1\nwhile true; do sleep 0.1; done\n"
+	if err := ioutil.WriteFile(cfg.GenesisBin(), []byte(script), 0755); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	manager := NewProcessManager(cfg, nil, ioutil.Discard, ioutil.Discard, nil)
+	exits := make(chan ExitEvent, 10)
+	go func() {
+		for evt := range manager.Exits() {
+			exits <- evt
+		}
+	}()
+	nextExit := func(t *testing.T) ExitEvent {
+		select {
+		case evt := <-exits:
+			return evt
+		case <-time.After(5 * time.Second):
+			t.Error("timed out waiting for the exit event")
+			t.FailNow()
+		}
+		return ExitEvent{}
+	}
+
+	if _, err := manager.Wait(); err != ErrNotRunning {
+		t.Errorf("expected ErrNotRunning before the first start, got %v", err)
+	}
+	first, err := manager.Start()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	// a second pocket-core must never run on the same data dir
+	if _, err := manager.Start(); errors.Cause(err) != ErrAlreadyRunning {
+		t.Errorf("expected ErrAlreadyRunning, got %v", err)
+	}
+	time.Sleep(100 * time.Millisecond) // let the shell install its traps
+
+	second, err := manager.Restart()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if second == first || manager.Current() != second || !manager.Running() {
+		t.Error("expected a new running process after the restart")
+	}
+	if evt := nextExit(t); evt.Cmd != first || !evt.Expected {
+		t.Errorf("expected the expected exit of the first process, got %s expected=%v", evt, evt.Expected)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if err := manager.Signal(syscall.SIGUSR1); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	evt, err := manager.Wait()
+	if err != nil || evt.Cmd != second || evt.ExitCode != 7 {
+		t.Errorf("expected the second process to exit with 7, got %s %v", evt, err)
+	}
+	if evt := nextExit(t); evt.Cmd != second || evt.Expected {
+		t.Errorf("an exit the runner did not ask for must not be expected, got %s expected=%v", evt, evt.Expected)
+	}
+	if err := manager.Signal(syscall.SIGTERM); err != ErrNotRunning {
+		t.Errorf("expected ErrNotRunning, got %v", err)
+	}
+
+	third, err := manager.Start()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	// the supervisor forgets the processes the manager replaced
+	if n := tracked(manager.supervisor); n != 1 {
+		t.Errorf("expected the supervisor to only track the third process, got %d entries", n)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if err := manager.Stop(); err != nil {
+		t.Error(err)
+	}
+	if evt := nextExit(t); evt.Cmd != third || !evt.Expected || manager.Running() {
+		t.Errorf("expected the third process to be stopped, got %s expected=%v", evt, evt.Expected)
+	}
+	// stopping again is a no-op
+	if err := manager.Stop(); err != nil {
+		t.Error(err)
+	}
+	// nothing starts pocket-core again once the manager was closed
+	if err := manager.Close(); err != nil {
+		t.Error(err)
+	}
+	if _, err := manager.Start(); err != ErrManagerClosed {
+		t.Errorf("expected ErrManagerClosed, got %v", err)
+	}
+}
+
+func TestProcessManagerWaitError(t *testing.T) {
+	cmd := exec.Command("sleep", "10")
+	if err := cmd.Start(); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	pid := cmd.Process.Pid
+	defer func() {
+		syscall.Kill(pid, syscall.SIGKILL)
+		var status syscall.WaitStatus
+		syscall.Wait4(pid, &status, 0, nil)
+	}()
+	// a released process cannot be waited for, cmd.Wait fails and leaves no ProcessState
+	if err := cmd.Process.Release(); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	manager := NewProcessManager(&types.Config{}, nil, ioutil.Discard, ioutil.Discard, nil)
+	manager.cmd = cmd
+	if _, err := manager.Wait(); err == nil {
+		t.Error("expected the error of cmd.Wait")
+	}
+}
+
+func TestProcessManagerSwitch(t *testing.T) {
+	machine, cleanup := newTestMachine(t, 5)
+	defer cleanup()
+	manager := machine.manager
+	first := manager.Current()
+
+	started := make(chan error, 1)
+	second, err := manager.Switch(func() error {
+		if manager.Running() {
+			return errors.New("pocket-core is running during the switch")
+		}
+		// a relaunch racing the switch waits for it
+		go func() {
+			_, err := manager.Start()
+			started <- err
+		}()
+		select {
+		case err := <-started:
+			return errors.Errorf("pocket-core was started during the switch: %v", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if second == first || manager.Current() != second || !manager.Running() {
+		t.Error("expected the switch to launch a new process")
+	}
+	if err := <-started; errors.Cause(err) != ErrAlreadyRunning {
+		t.Errorf("expected the racing start to find pocket-core running, got %v", err)
+	}
+
+	// pocket-core is left stopped when the change fails
+	failure := errors.New("switch failed")
+	if _, err := manager.Switch(func() error { return failure }); err != failure {
+		t.Errorf("expected the error of the change, got %v", err)
+	}
+	if manager.Running() {
+		t.Error("expected pocket-core to be left stopped")
+	}
+}
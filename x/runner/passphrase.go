@@ -0,0 +1,243 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/pokt-network/pocket-runner/internal/types"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// maxPassphraseSize bounds what is read from a passphrase file or descriptor
+const maxPassphraseSize = 4096
+
+var (
+	// ErrNoPassphrase occurs when the passphrase source is empty or cannot be read
+	ErrNoPassphrase = errors.New("no passphrase")
+	// ErrPassphraseWiped occurs when a passphrase kept in memory is requested after Wipe
+	ErrPassphraseWiped = errors.New("passphrase was wiped")
+	// ErrPassphraseTooLong occurs when the passphrase source holds more than maxPassphraseSize bytes
+	ErrPassphraseTooLong = errors.New("passphrase too long")
+)
+
+// Passphrase feeds the passphrase of the node to every pocket-core the runner launches.
+// The file source is read again for every launch, the env, fd and prompt sources can only be read once
+// so their passphrase is kept in memory until Wipe. Every copy handed to a process is zeroed once written.
+// The passphrase never appears in the output of the fmt verbs, it is not meant to be logged.
+type Passphrase struct {
+	policy types.PassphrasePolicy
+	mu     sync.Mutex
+	secret []byte
+	wiped  bool
+}
+
+// NewPassphrase reads the passphrase of the sources that can only be read once, it prompts for it with the prompt source
+func NewPassphrase(policy types.PassphrasePolicy) (*Passphrase, error) {
+	p := &Passphrase{policy: policy}
+	var (
+		secret []byte
+		err    error
+	)
+	switch policy.Source {
+	case types.PassphraseSourceFile:
+		// fail on startup rather than on the first relaunch
+		if secret, err = readPassphraseFile(policy.File); err != nil {
+			return nil, err
+		}
+		wipe(secret)
+		return p, nil
+	case types.PassphraseSourceEnv:
+		value, ok := os.LookupEnv(types.PassphraseEnv)
+		if !ok {
+			return nil, errors.Wrapf(ErrNoPassphrase, "%s is not set", types.PassphraseEnv)
+		}
+		// pocket-core inherits the environment of the runner, it must not find the passphrase there
+		if err := os.Unsetenv(types.PassphraseEnv); err != nil {
+			return nil, errors.Wrapf(err, "cannot remove %s from the environment", types.PassphraseEnv)
+		}
+		secret = trimNewline([]byte(value))
+	case types.PassphraseSourceFD:
+		f := os.NewFile(uintptr(policy.FD), "passphrase")
+		if f == nil {
+			return nil, errors.Wrapf(ErrNoPassphrase, "invalid passphrase fd %d", policy.FD)
+		}
+		secret, err = readPassphrase(f)
+		f.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "passphrase fd %d", policy.FD)
+		}
+	case types.PassphraseSourcePrompt:
+		if secret, err = promptPassphrase(); err != nil {
+			return nil, err
+		}
+	default:
+		return p, nil
+	}
+	if len(secret) == 0 {
+		return nil, errors.Wrapf(ErrNoPassphrase, "empty passphrase from the %s source", policy.Source)
+	}
+	p.secret = secret
+	return p, nil
+}
+
+// Stdin returns the stdin of a new pocket-core: the stdin of the runner with the inherit source,
+// a reader writing the passphrase and a new line otherwise. The reader zeroes its copy as it is consumed.
+func (p *Passphrase) Stdin() (io.Reader, error) {
+	if p == nil || p.policy.Source == "" || p.policy.Source == types.PassphraseSourceInherit {
+		return os.Stdin, nil
+	}
+	if p.policy.Source == types.PassphraseSourceFile {
+		secret, err := readPassphraseFile(p.policy.File)
+		if err != nil {
+			return nil, err
+		}
+		defer wipe(secret)
+		return newSecretReader(secret), nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.wiped {
+		return nil, ErrPassphraseWiped
+	}
+	return newSecretReader(p.secret), nil
+}
+
+// Wipe zeroes the passphrase kept in memory
+func (p *Passphrase) Wipe() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	wipe(p.secret)
+	p.secret = nil
+	p.wiped = true
+}
+
+// String describes the source of the passphrase, never the passphrase itself
+func (p *Passphrase) String() string {
+	if p == nil {
+		return "passphrase from the inherit source"
+	}
+	return fmt.Sprintf("passphrase from the %s source", p.policy.Source)
+}
+
+// GoString keeps %#v from printing the passphrase
+func (p *Passphrase) GoString() string {
+	return p.String()
+}
+
+// secretReader yields a copy of the passphrase once, the bytes are zeroed as soon as they are handed over
+type secretReader struct {
+	secret []byte
+	offset int
+}
+
+// newSecretReader returns a reader of a copy of secret followed by a new line
+func newSecretReader(secret []byte) *secretReader {
+	line := make([]byte, len(secret)+1)
+	copy(line, secret)
+	line[len(secret)] = '\n'
+	return &secretReader{secret: line}
+}
+
+func (r *secretReader) Read(b []byte) (int, error) {
+	if r.offset >= len(r.secret) {
+		r.Wipe()
+		return 0, io.EOF
+	}
+	n := copy(b, r.secret[r.offset:])
+	wipe(r.secret[r.offset : r.offset+n])
+	r.offset += n
+	return n, nil
+}
+
+// WriteTo is used by io.Copy, and so by os/exec, it writes without an intermediate buffer
+func (r *secretReader) WriteTo(w io.Writer) (int64, error) {
+	defer r.Wipe()
+	n, err := w.Write(r.secret[r.offset:])
+	r.offset += n
+	return int64(n), err
+}
+
+// Wipe zeroes what remains of the passphrase, for readers that are not consumed
+func (r *secretReader) Wipe() {
+	wipe(r.secret)
+	r.offset = len(r.secret)
+}
+
+func (r *secretReader) String() string {
+	return "<passphrase>"
+}
+
+// readPassphraseFile reads the passphrase stored in file
+func readPassphraseFile(file string) ([]byte, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot open the passphrase file")
+	}
+	defer f.Close()
+	secret, err := readPassphrase(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "passphrase file %s", file)
+	}
+	return secret, nil
+}
+
+// readPassphrase reads the passphrase from r, up to maxPassphraseSize bytes, without its trailing new line.
+// It reads into a single buffer, a growing one would leave copies of the passphrase behind.
+// A source holding more than maxPassphraseSize bytes fails rather than being truncated.
+func readPassphrase(r io.Reader) ([]byte, error) {
+	secret := make([]byte, maxPassphraseSize)
+	n, err := io.ReadFull(r, secret)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		wipe(secret)
+		return nil, err
+	}
+	if err == nil {
+		var extra [1]byte
+		if m, _ := io.ReadFull(r, extra[:]); m != 0 {
+			wipe(secret)
+			wipe(extra[:])
+			return nil, errors.Wrapf(ErrPassphraseTooLong, "more than %d bytes", maxPassphraseSize)
+		}
+	}
+	if secret = trimNewline(secret[:n]); len(secret) == 0 {
+		return nil, ErrNoPassphrase
+	}
+	return secret, nil
+}
+
+// promptPassphrase asks for the passphrase on the terminal of the runner without echoing it
+func promptPassphrase() ([]byte, error) {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		return nil, errors.Wrap(ErrNoPassphrase, "the prompt passphrase source needs a terminal")
+	}
+	fmt.Fprint(os.Stderr, "Enter the passphrase of pocket-core: ")
+	secret, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read the passphrase")
+	}
+	return trimNewline(secret), nil
+}
+
+// trimNewline drops the line endings at the end of secret, in place
+func trimNewline(secret []byte) []byte {
+	for len(secret) > 0 && (secret[len(secret)-1] == '\n' || secret[len(secret)-1] == '\r') {
+		secret[len(secret)-1] = 0
+		secret = secret[:len(secret)-1]
+	}
+	return secret
+}
+
+// wipe zeroes b
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,142 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/pokt-network/pocket-runner/internal/types"
+)
+
+const testPassphrase = "correct horse battery staple"
+
+func TestPassphrase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "passphrase")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "passphrase")
+	if err := ioutil.WriteFile(file, []byte(testPassphrase+"\r\n"), 0600); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer r.Close()
+	if _, err := w.Write([]byte(testPassphrase + "\n")); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	w.Close()
+
+	cases := map[string]struct {
+		policy types.PassphrasePolicy
+		env    bool
+	}{
+		"file": {policy: types.PassphrasePolicy{Source: types.PassphraseSourceFile, File: file}},
+		"env":  {policy: types.PassphrasePolicy{Source: types.PassphraseSourceEnv}, env: true},
+		"fd":   {policy: types.PassphrasePolicy{Source: types.PassphraseSourceFD, FD: int(r.Fd())}},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if tc.env {
+				os.Setenv(types.PassphraseEnv, testPassphrase)
+			}
+			passphrase, err := NewPassphrase(tc.policy)
+			if err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+			if _, ok := os.LookupEnv(types.PassphraseEnv); ok {
+				t.Errorf("%s must not be passed on to pocket-core", types.PassphraseEnv)
+			}
+			for _, verb := range []string{"%v", "%+v", "%#v", "%s"} {
+				if s := fmt.Sprintf(verb, passphrase); strings.Contains(s, testPassphrase) {
+					t.Errorf("%s prints the passphrase: %s", verb, s)
+				}
+			}
+			// every launch gets the passphrase, not only the first one
+			for launch := 0; launch < 2; launch++ {
+				stdin, err := passphrase.Stdin()
+				if err != nil {
+					t.Error(err)
+					t.FailNow()
+				}
+				cmd := exec.Command("sh", "-c", `read p; [ "$p" = "`+testPassphrase+`" ] && echo ok`)
+				cmd.Stdin = stdin
+				if out, err := cmd.Output(); err != nil || string(out) != "ok\n" {
+					t.Errorf("launch %d: pocket-core did not read the passphrase, got %q %v", launch, out, err)
+				}
+				if secret := stdin.(*secretReader).secret; !bytes.Equal(secret, make([]byte, len(secret))) {
+					t.Errorf("launch %d: the passphrase handed to pocket-core was not wiped", launch)
+				}
+			}
+			passphrase.Wipe()
+			if _, err := passphrase.Stdin(); tc.policy.Source != types.PassphraseSourceFile && err != ErrPassphraseWiped {
+				t.Errorf("expected ErrPassphraseWiped, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPassphraseErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "passphrase")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	empty := filepath.Join(dir, "empty")
+	if err := ioutil.WriteFile(empty, []byte("\n"), 0600); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	limit, long := filepath.Join(dir, "limit"), filepath.Join(dir, "long")
+	if err := ioutil.WriteFile(limit, bytes.Repeat([]byte("a"), maxPassphraseSize), 0600); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err := ioutil.WriteFile(long, bytes.Repeat([]byte("a"), maxPassphraseSize+1), 0600); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	os.Unsetenv(types.PassphraseEnv)
+
+	cases := map[string]struct {
+		policy    types.PassphrasePolicy
+		expectErr error
+	}{
+		"empty file":  {policy: types.PassphrasePolicy{Source: types.PassphraseSourceFile, File: empty}, expectErr: ErrNoPassphrase},
+		"missing env": {policy: types.PassphrasePolicy{Source: types.PassphraseSourceEnv}, expectErr: ErrNoPassphrase},
+		"limit file":  {policy: types.PassphrasePolicy{Source: types.PassphraseSourceFile, File: limit}},
+		"long file":   {policy: types.PassphrasePolicy{Source: types.PassphraseSourceFile, File: long}, expectErr: ErrPassphraseTooLong},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewPassphrase(tc.policy); errors.Cause(err) != tc.expectErr {
+				t.Errorf("expected %v, got %v", tc.expectErr, err)
+			}
+		})
+	}
+
+	// the runner's own stdin is handed over with the inherit source
+	passphrase, err := NewPassphrase(types.DefaultPassphrasePolicy())
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if stdin, err := passphrase.Stdin(); err != nil || stdin != os.Stdin {
+		t.Errorf("expected the stdin of the runner, got %v %v", stdin, err)
+	}
+}
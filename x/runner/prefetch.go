@@ -0,0 +1,143 @@
+package runner
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pokt-network/pocket-runner/internal/types"
+)
+
+var (
+	// prefetchBackoff is the delay before retrying a failed prefetch, it doubles after every failure
+	prefetchBackoff = 10 * time.Second
+	// prefetchMaxBackoff caps the delay between prefetch attempts
+	prefetchMaxBackoff = 5 * time.Minute
+)
+
+// ErrBinaryMissing occurs when the binary of an upgrade is not installed by the time the upgrade is due
+var ErrBinaryMissing = errors.New("upgrade binary is missing")
+
+// EnsureBinary checks the upgrade binary is in place, downloading it if allowed
+func EnsureBinary(ctx context.Context, cfg *types.Config, upgrade *types.UpgradeInfo) error {
+	err := types.CheckBinary(cfg.UpgradeBin(upgrade.Name))
+	if err == nil {
+		return nil
+	}
+	if !cfg.AllowDownload {
+		return err
+	}
+	return DownloadBinary(ctx, cfg, upgrade)
+}
+
+// Prefetcher acquires the binaries of scheduled upgrades in the background, between the moment an upgrade
+// is scheduled and its height. Failed attempts are logged as warnings and retried with a backoff,
+// only Acquire, when the upgrade is due, reports a missing binary as an error.
+type Prefetcher struct {
+	cfg    *types.Config
+	ctx    context.Context
+	cancel context.CancelFunc
+	jobs   map[string]*prefetchJob
+	mu     sync.Mutex
+}
+
+// prefetchJob is the background acquisition of one upgrade
+type prefetchJob struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewPrefetcher returns a prefetcher whose jobs end when ctx is done
+func NewPrefetcher(ctx context.Context, cfg *types.Config) *Prefetcher {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Prefetcher{cfg: cfg, ctx: ctx, cancel: cancel, jobs: make(map[string]*prefetchJob)}
+}
+
+// Prefetch starts acquiring the binary of upgrade unless it is installed or already being acquired
+func (p *Prefetcher) Prefetch(upgrade *types.UpgradeInfo) {
+	if types.CheckBinary(p.cfg.UpgradeBin(upgrade.Name)) == nil {
+		return
+	}
+	if !p.cfg.AllowDownload {
+		log.Printf("WARNING: the binary for upgrade %s is not installed and DAEMON_ALLOW_DOWNLOAD is off, install it before height %d\n", upgrade.Name, upgrade.Height)
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.jobs[upgrade.Name]; ok || p.ctx.Err() != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(p.ctx)
+	job := &prefetchJob{cancel: cancel, done: make(chan struct{})}
+	p.jobs[upgrade.Name] = job
+	go p.run(ctx, job, upgrade)
+}
+
+// run retries acquiring the binary of upgrade until it succeeds or ctx is done
+func (p *Prefetcher) run(ctx context.Context, job *prefetchJob, upgrade *types.UpgradeInfo) {
+	defer close(job.done)
+	backoff := prefetchBackoff
+	for attempt := 1; ; attempt++ {
+		err := EnsureBinary(ctx, p.cfg, upgrade)
+		if err == nil {
+			log.Printf("binary for upgrade %s is ready\n", upgrade.Name)
+			p.mu.Lock()
+			if p.jobs[upgrade.Name] == job {
+				delete(p.jobs, upgrade.Name)
+			}
+			p.mu.Unlock()
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("WARNING: attempt %d to fetch upgrade %s failed, retrying in %s: %v\n", attempt, upgrade.Name, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		if backoff *= 2; backoff > prefetchMaxBackoff {
+			backoff = prefetchMaxBackoff
+		}
+	}
+}
+
+// Cancel stops acquiring the binary of the upgrade named name and waits for the job to end
+func (p *Prefetcher) Cancel(name string) {
+	p.mu.Lock()
+	job, ok := p.jobs[name]
+	delete(p.jobs, name)
+	p.mu.Unlock()
+	if !ok {
+		return
+	}
+	job.cancel()
+	<-job.done
+}
+
+// Acquire is called once upgrade is due: it stops prefetching it and makes a last attempt to install its binary,
+// which resumes a partial download. It returns ErrBinaryMissing if the binary is still not installed.
+func (p *Prefetcher) Acquire(ctx context.Context, upgrade *types.UpgradeInfo) error {
+	p.Cancel(upgrade.Name)
+	if err := EnsureBinary(ctx, p.cfg, upgrade); err != nil {
+		return errors.Wrapf(ErrBinaryMissing, "upgrade %s at height %d: %v", upgrade.Name, upgrade.Height, err)
+	}
+	return nil
+}
+
+// Stop cancels every job and waits for them to end
+func (p *Prefetcher) Stop() {
+	p.cancel()
+	p.mu.Lock()
+	names := make([]string, 0, len(p.jobs))
+	for name := range p.jobs {
+		names = append(names, name)
+	}
+	p.mu.Unlock()
+	for _, name := range names {
+		p.Cancel(name)
+	}
+}
@@ -0,0 +1,129 @@
+package runner
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pokt-network/pocket-runner/internal/types"
+)
+
+func TestPrefetcher(t *testing.T) {
+	defer func(backoff time.Duration) { prefetchBackoff = backoff }(prefetchBackoff)
+	prefetchBackoff = 10 * time.Millisecond
+
+	home, err := copyTestData("validate")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(home)
+
+	asset := "test-runnerd_RC-0.3.0_" + runtime.GOOS + "_" + runtime.GOARCH + ".tar.gz"
+	archive := testArchive(t, "tar.gz", "test-runnerd", testBinary)
+	var (
+		mu       sync.Mutex
+		attempts int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/RC-0.3.0/SHA256SUMS":
+			_, _ = w.Write([]byte(sha256Hex(archive) + "  " + asset + "\n"))
+		case "/RC-0.3.0/" + asset:
+			mu.Lock()
+			attempts++
+			failing := attempts <= 2
+			mu.Unlock()
+			// the mirror is flaky until the third attempt
+			if failing {
+				http.Error(w, "try again later", http.StatusBadGateway)
+				return
+			}
+			_, _ = w.Write(archive)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &types.Config{Home: home, Name: "test-runnerd", AllowDownload: true, Download: types.DownloadPolicy{
+		ReleaseMirrors: []types.Mirror{{URL: server.URL + "/{version}/{name}_{version}_{os}_{arch}.tar.gz", Timeout: time.Second}},
+		SkipCompile:    true,
+	}}
+	prefetcher := NewPrefetcher(context.Background(), cfg)
+	defer prefetcher.Stop()
+
+	upgrade := &types.UpgradeInfo{Name: "RC-0.3.0", Version: "RC-0.3.0", Height: 100}
+	prefetcher.Prefetch(upgrade)
+	deadline := time.Now().Add(5 * time.Second)
+	for types.CheckBinary(cfg.UpgradeBin(upgrade.Name)) != nil {
+		if time.Now().After(deadline) {
+			t.Error("the binary was not prefetched")
+			t.FailNow()
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mu.Lock()
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts)
+	}
+	mu.Unlock()
+	if err := prefetcher.Acquire(context.Background(), upgrade); err != nil {
+		t.Error(err)
+	}
+
+	// an upgrade whose binary cannot be fetched only fails once it is due
+	missing := &types.UpgradeInfo{Name: "RC-0.3.1", Version: "RC-0.3.1", Height: 200}
+	prefetcher.Prefetch(missing)
+	time.Sleep(50 * time.Millisecond)
+	if err := prefetcher.Acquire(context.Background(), missing); errors.Cause(err) != ErrBinaryMissing {
+		t.Errorf("expected ErrBinaryMissing, got %v", err)
+	}
+	if _, ok := prefetcher.jobs[missing.Name]; ok {
+		t.Error("expected the prefetch job to be cancelled")
+	}
+}
+
+func TestPrefetcherDownloadDisabled(t *testing.T) {
+	home, err := copyTestData("validate")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(home)
+
+	cfg := &types.Config{Home: home, Name: "test-runnerd"}
+	prefetcher := NewPrefetcher(context.Background(), cfg)
+	defer prefetcher.Stop()
+
+	cases := map[string]struct {
+		upgrade   *types.UpgradeInfo
+		expectErr bool
+	}{
+		"installed": {upgrade: &types.UpgradeInfo{Name: "RC-0.2.0", Height: 10}},
+		"missing":   {upgrade: &types.UpgradeInfo{Name: "RC-0.3.0", Height: 10}, expectErr: true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			prefetcher.Prefetch(tc.upgrade)
+			if len(prefetcher.jobs) != 0 {
+				t.Errorf("no job must be started when downloads are disabled, got %d", len(prefetcher.jobs))
+			}
+			err := prefetcher.Acquire(context.Background(), tc.upgrade)
+			if tc.expectErr {
+				if errors.Cause(err) != ErrBinaryMissing || !strings.Contains(err.Error(), tc.upgrade.Name) {
+					t.Errorf("expected ErrBinaryMissing for %s, got %v", tc.upgrade.Name, err)
+				}
+			} else if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package runner
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pokt-network/pocket-runner/internal/types"
+)
+
+// versionTimeout bounds `<bin> version` when a staged binary is checked
+var versionTimeout = 30 * time.Second
+
+// PrepareOptions tells PrepareUpgrade where the binary of the upgrade comes from
+type PrepareOptions struct {
+	// From is a local binary installed instead of downloading the upgrade
+	From string
+	// Checksum is the expected SHA-256 of the downloaded artifact, or of From
+	Checksum string
+	// Force replaces a binary already installed for the upgrade
+	Force bool
+}
+
+// PrepareUpgrade stages the binary of the upgrade name in cfg.UpgradeBin ahead of its height, by copying opts.From
+// or by downloading the release or the source of the upgrade, even when DAEMON_ALLOW_DOWNLOAD is off.
+// The binary must pass `<bin> version`, whose output is returned. A binary that was just staged and fails
+// the check is removed so the runner never switches to it. An installed binary is only checked unless opts.Force is set.
+func PrepareUpgrade(ctx context.Context, cfg *types.Config, name string, opts PrepareOptions) (string, error) {
+	if err := types.ValidateUpgradeName(name); err != nil {
+		return "", err
+	}
+	bin := cfg.UpgradeBin(name)
+	if types.CheckBinary(bin) == nil && !opts.Force {
+		log.Printf("upgrade %s is already installed at %s, checking it\n", name, bin)
+		return BinaryVersion(ctx, bin)
+	}
+	if err := os.Remove(bin); err != nil && !os.IsNotExist(err) {
+		return "", errors.Wrapf(err, "cannot replace %s", bin)
+	}
+
+	var err error
+	if opts.From != "" {
+		err = installLocalBinary(opts.From, bin, opts.Checksum)
+	} else {
+		err = DownloadBinary(ctx, cfg, &types.UpgradeInfo{Name: name, Version: name, Checksum: opts.Checksum})
+	}
+	if err == nil {
+		err = types.CheckBinary(bin)
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot stage upgrade %s", name)
+	}
+
+	version, err := BinaryVersion(ctx, bin)
+	if err != nil {
+		os.Remove(bin)
+		return "", errors.Wrapf(err, "staged binary of upgrade %s was removed", name)
+	}
+	return version, nil
+}
+
+// installLocalBinary copies the binary at src to bin, after checking its SHA-256 if checksum is set
+func installLocalBinary(src, bin, checksum string) error {
+	if checksum != "" {
+		if err := VerifyChecksum(src, checksum); err != nil {
+			return errors.Wrapf(err, "binary %s", src)
+		}
+	}
+	if err := types.Copy(src, bin, types.Options{Atomic: true, MkdirAll: true}); err != nil {
+		return errors.Wrapf(err, "cannot copy %s", src)
+	}
+	// the copy keeps the mode of src, the runner needs a world executable binary
+	return errors.Wrapf(os.Chmod(bin, 0755), "cannot make %s executable", bin)
+}
+
+// BinaryVersion runs `<bin> version` and returns its output, the binary must exit successfully within versionTimeout
+func BinaryVersion(ctx context.Context, bin string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, versionTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, bin, "version").CombinedOutput()
+	version := strings.TrimSpace(string(out))
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", errors.Errorf("%s version did not exit within %s", bin, versionTimeout)
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "%s version failed: %s", bin, version)
+	}
+	return version, nil
+}
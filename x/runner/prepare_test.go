@@ -0,0 +1,117 @@
+package runner
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pokt-network/pocket-runner/internal/types"
+)
+
+func TestPrepareUpgrade(t *testing.T) {
+	home, err := copyTestData("validate")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(home)
+
+	// writeScript writes an executable only its owner may run, the staged copy must be world executable
+	writeScript := func(name, script string) string {
+		file := filepath.Join(home, name)
+		if err := ioutil.WriteFile(file, []byte("#!/bin/sh\n"+script+"\n"), 0700); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		return file
+	}
+	good := writeScript("good", `[ "$1" = version ] && echo RC-0.3.0`)
+	broken := writeScript("broken", "echo corrupted >&2; exit 1")
+	other := writeScript("other", "echo RC-0.3.1")
+
+	// the cases staging RC-0.3.0 build on each other, they run in order
+	cases := []struct {
+		desc      string
+		name      string
+		opts      PrepareOptions
+		expect    string
+		expectErr error
+		installed bool
+	}{
+		{desc: "local binary", name: "RC-0.3.0", opts: PrepareOptions{From: good}, expect: "RC-0.3.0", installed: true},
+		{desc: "installed is checked", name: "RC-0.3.0", opts: PrepareOptions{From: other}, expect: "RC-0.3.0", installed: true},
+		{desc: "forced replacement", name: "RC-0.3.0", opts: PrepareOptions{From: other, Force: true}, expect: "RC-0.3.1", installed: true},
+		{desc: "failing version", name: "RC-0.4.0", opts: PrepareOptions{From: broken}},
+		{desc: "checksum mismatch", name: "RC-0.5.0", opts: PrepareOptions{From: good, Checksum: sha256Hex([]byte("other"))}, expectErr: ErrChecksumMismatch},
+		{desc: "invalid name", name: "../RC-0.6.0", opts: PrepareOptions{From: good}, expectErr: types.ErrMalformedUpgrade},
+	}
+	cfg := &types.Config{Home: home, Name: "test-runnerd"}
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			version, err := PrepareUpgrade(context.Background(), cfg, tc.name, tc.opts)
+			switch {
+			case tc.expect != "":
+				if err != nil || version != tc.expect {
+					t.Errorf("expected version %s, got %q %v", tc.expect, version, err)
+				}
+			case tc.expectErr != nil:
+				if errors.Cause(err) != tc.expectErr {
+					t.Errorf("expected %v, got %v", tc.expectErr, err)
+				}
+			case err == nil:
+				t.Errorf("expected an error, got version %q", version)
+			}
+			if tc.expectErr == types.ErrMalformedUpgrade {
+				return
+			}
+			if err := types.CheckBinary(cfg.UpgradeBin(tc.name)); (err == nil) != tc.installed {
+				t.Errorf("expected installed %t, got %v", tc.installed, err)
+			}
+		})
+	}
+}
+
+func TestPrepareUpgradeDownload(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil || testing.Short() {
+		t.Skip("builds need a go toolchain and compile the standard library in a fresh cache")
+	}
+	home, err := copyTestData("validate")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(home)
+
+	// the binary prints the version it was built for
+	server := newSourceMirror(t, "RC-0.7.0", `package main
+
+import "fmt"
+
+func main() { fmt.Println("RC-0.7.0") }
+`)
+	defer server.Close()
+
+	// DAEMON_ALLOW_DOWNLOAD is off, prepare-upgrade downloads anyway; nothing is staged for RC-0.7.0
+	cfg := &types.Config{Home: home, Name: "test-runnerd", Download: types.DownloadPolicy{
+		SourceMirrors: []types.Mirror{{URL: server.URL + "/{version}.zip", Timeout: time.Second}},
+	}}
+	version, err := PrepareUpgrade(context.Background(), cfg, "RC-0.7.0", PrepareOptions{})
+	if err != nil || version != "RC-0.7.0" {
+		t.Errorf("expected version RC-0.7.0, got %q %v", version, err)
+	}
+	if err := types.CheckBinary(cfg.UpgradeBin("RC-0.7.0")); err != nil {
+		t.Error(err)
+	}
+	// a version the mirror does not publish stages nothing
+	if _, err := PrepareUpgrade(context.Background(), cfg, "RC-0.7.1", PrepareOptions{}); err == nil {
+		t.Error("expected an error for a missing source")
+	}
+	if err := types.CheckBinary(cfg.UpgradeBin("RC-0.7.1")); err == nil {
+		t.Error("expected RC-0.7.1 not to be installed")
+	}
+}
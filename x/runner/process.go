@@ -29,3 +29,16 @@ func LaunchProcess(cfg *types.Config, args []string, stdout, stderr io.Writer, s
 
 	return cmd, nil
 }
+
+// LaunchWithPassphrase runs a subprocess like LaunchProcess, with passphrase written to its stdin
+func LaunchWithPassphrase(cfg *types.Config, args []string, stdout, stderr io.Writer, passphrase *Passphrase) (*exec.Cmd, error) {
+	stdin, err := passphrase.Stdin()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read the passphrase")
+	}
+	cmd, err := LaunchProcess(cfg, args, stdout, stderr, stdin)
+	if r, ok := stdin.(*secretReader); ok && err != nil {
+		r.Wipe()
+	}
+	return cmd, err
+}
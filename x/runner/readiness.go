@@ -0,0 +1,69 @@
+package runner
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pokt-network/pocket-runner/internal/types"
+	"github.com/tendermint/tendermint/rpc/client"
+	coreTypes "github.com/tendermint/tendermint/rpc/core/types"
+	rpcClient "github.com/tendermint/tendermint/rpc/lib/client"
+)
+
+// ErrNotReady occurs when pocket-core's rpc did not answer before the readiness probe timed out
+var ErrNotReady = errors.New("pocket-core rpc is not ready")
+
+// WaitForReady polls the /status endpoint of pocket-core until it answers, returns the first successful status.
+// It gives up once the readiness probe times out or ctx is cancelled.
+func WaitForReady(ctx context.Context, cfg *types.Config) (*coreTypes.ResultStatus, error) {
+	probe := cfg.Readiness.WithDefaults()
+	ctx, cancel := context.WithTimeout(ctx, probe.Timeout)
+	defer cancel()
+
+	address := cfg.RPCAddress()
+	statusClient := newStatusClient(cfg)
+	for attempt := 1; ; attempt++ {
+		status, err := statusClient.Status()
+		if err == nil {
+			log.Printf("pocket-core rpc is ready at height %d\n", status.SyncInfo.LatestBlockHeight)
+			return status, nil
+		}
+		log.Printf("waiting for pocket-core rpc on %s (attempt %d): %v\n", address, attempt, err)
+		select {
+		case <-time.After(probeDelay(probe)):
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return nil, errors.Wrapf(ErrNotReady, "no answer from %s after %s: %v", address, probe.Timeout, err)
+			}
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// LatestHeight asks pocket-core for the height of its latest block
+func LatestHeight(cfg *types.Config) (int64, error) {
+	status, err := newStatusClient(cfg).Status()
+	if err != nil {
+		return 0, errors.Wrap(err, "could not query the latest block height")
+	}
+	return status.SyncInfo.LatestBlockHeight, nil
+}
+
+// newStatusClient returns an rpc client whose requests are bounded by the readiness probe request timeout
+func newStatusClient(cfg *types.Config) client.Client {
+	address := cfg.RPCAddress()
+	httpClient := rpcClient.DefaultHTTPClient(address)
+	httpClient.Timeout = cfg.Readiness.WithDefaults().RequestTimeout
+	return client.NewHTTPWithClient(address, "/websocket", httpClient)
+}
+
+// probeDelay is the probe interval plus a random jitter, so restarted runners don't poll in lockstep
+func probeDelay(probe types.ReadinessProbe) time.Duration {
+	if probe.Jitter <= 0 {
+		return probe.Interval
+	}
+	return probe.Interval + time.Duration(rand.Int63n(int64(probe.Jitter)))
+}
@@ -0,0 +1,88 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pokt-network/pocket-runner/internal/types"
+)
+
+// newStatusServer fakes the tendermint /status rpc, it fails the first `failures` requests
+func newStatusServer(t *testing.T, failures int32, height int64) (server *httptest.Server, port string) {
+	var calls int32
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var req struct {
+			ID json.RawMessage `json:"id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"sync_info":{"latest_block_height":"%d","catching_up":false}}}`, req.ID, height)
+	}))
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	return server, port
+}
+
+func TestWaitForReady(t *testing.T) {
+	server, port := newStatusServer(t, 2, 7)
+	defer server.Close()
+	cfg := &types.Config{Name: "test-runnerd", Port: port, Readiness: types.ReadinessProbe{
+		Timeout:  5 * time.Second,
+		Interval: 10 * time.Millisecond,
+		Jitter:   10 * time.Millisecond,
+	}}
+	status, err := WaitForReady(context.Background(), cfg)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if status.SyncInfo.LatestBlockHeight != 7 {
+		t.Errorf("latest height = %d, want 7", status.SyncInfo.LatestBlockHeight)
+	}
+}
+
+func TestWaitForReadyTimeout(t *testing.T) {
+	server, port := newStatusServer(t, 1<<30, 0)
+	defer server.Close()
+	cfg := &types.Config{Name: "test-runnerd", Port: port, Readiness: types.ReadinessProbe{
+		Timeout:  200 * time.Millisecond,
+		Interval: 10 * time.Millisecond,
+	}}
+	if _, err := WaitForReady(context.Background(), cfg); errors.Cause(err) != ErrNotReady {
+		t.Errorf("expected ErrNotReady, got %v", err)
+	}
+}
+
+func TestLatestHeight(t *testing.T) {
+	server, port := newStatusServer(t, 0, 42)
+	cfg := &types.Config{Name: "test-runnerd", Port: port}
+	height, err := LatestHeight(cfg)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if height != 42 {
+		t.Errorf("latest height = %d, want 42", height)
+	}
+	server.Close()
+	if _, err := LatestHeight(cfg); err == nil {
+		t.Error("expected an error once pocket-core is gone")
+	}
+}
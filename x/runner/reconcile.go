@@ -0,0 +1,42 @@
+package runner
+
+import (
+	"log"
+
+	"github.com/pkg/errors"
+	"github.com/pokt-network/pocket-runner/internal/types"
+	"github.com/pokt-network/posmint/x/gov"
+	govTypes "github.com/pokt-network/posmint/x/gov/types"
+	"github.com/tendermint/tendermint/rpc/client"
+)
+
+// PendingUpgrade asks the chain for the upgrade scheduled by governance, so upgrades committed while the
+// runner was offline are not missed. Returns nil if nothing is scheduled or the upgrade is already running.
+func PendingUpgrade(cfg *types.Config, tmClient client.Client) (*types.UpgradeInfo, error) {
+	upgrade, err := gov.QueryUpgrade(gov.ModuleCdc, tmClient, 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not query the scheduled upgrade")
+	}
+	return scheduledUpgrade(cfg, upgrade)
+}
+
+// scheduledUpgrade turns the upgrade param of governance into the upgrade to wait for, see PendingUpgrade.
+// The version names a directory under upgrades, it is validated like the versions of upgrade txs.
+func scheduledUpgrade(cfg *types.Config, upgrade govTypes.Upgrade) (*types.UpgradeInfo, error) {
+	if upgrade.Version == "" || upgrade.Height == 0 {
+		return nil, nil
+	}
+	if err := types.ValidateUpgradeName(upgrade.Version); err != nil {
+		return nil, errors.Wrapf(err, "scheduled upgrade at height %d", upgrade.Height)
+	}
+	if current := cfg.CurrentUpgrade(); current == upgrade.Version {
+		log.Printf("scheduled upgrade %s at height %d is already running\n", upgrade.Version, upgrade.Height)
+		return nil, nil
+	}
+	log.Printf("found upgrade %s scheduled at height %d\n", upgrade.Version, upgrade.Height)
+	return &types.UpgradeInfo{
+		Name:    upgrade.Version,
+		Version: upgrade.Version,
+		Height:  upgrade.Height,
+	}, nil
+}
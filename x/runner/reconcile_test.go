@@ -0,0 +1,123 @@
+package runner
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pokt-network/pocket-runner/internal/types"
+	"github.com/pokt-network/posmint/x/gov"
+	govTypes "github.com/pokt-network/posmint/x/gov/types"
+	tmTypes "github.com/tendermint/tendermint/types"
+)
+
+func TestPendingUpgrade(t *testing.T) {
+	const version = "RC-0.2.0"
+	resetTestACL() // the upgrade tx must be signed by this node's coinbase
+	_, kb, cleanup := NewInMemoryTendermintNode(t, oneValTwoNodeGenesisState())
+	defer cleanup()
+	cb, err := kb.GetCoinbase()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	home, err := copyTestData("validate")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(home)
+	cfg := &types.Config{Home: home, Name: "test-runnerd", Port: "36657"}
+
+	memCli, stopCli, evtChan := subscribeTo(t, tmTypes.EventNewBlock)
+	<-evtChan
+	stopCli()
+	pending, err := PendingUpgrade(cfg, TMClient(cfg.RPCAddress()))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	// the test genesis already schedules an upgrade, it was never seen as a tx
+	if pending == nil || pending.Name != "2.0.0" || pending.Height != 10000 {
+		t.Errorf("expected the genesis upgrade to be pending, got %+v", pending)
+	}
+
+	memCli, stopCli, evtChan = subscribeTo(t, tmTypes.EventTx)
+	defer stopCli()
+	if _, err := gov.UpgradeTx(memCodec(), memCli, kb, cb.GetAddress(), govTypes.Upgrade{
+		Height:  100,
+		Version: version,
+	}, "test"); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	<-evtChan // the upgrade tx was committed
+
+	// the query may still be answered from the previous state until the next block
+	for i := 0; i < 20; i++ {
+		pending, err = PendingUpgrade(cfg, TMClient(cfg.RPCAddress()))
+		if err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		if pending != nil && pending.Name == version {
+			break
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+	if pending == nil || pending.Name != version || pending.Height != 100 {
+		t.Errorf("unexpected pending upgrade %+v", pending)
+		t.FailNow()
+	}
+
+	// once the upgrade is current there is nothing left to catch up on
+	if err := cfg.SetCurrentUpgrade(version); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	pending, err = PendingUpgrade(cfg, TMClient(cfg.RPCAddress()))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if pending != nil {
+		t.Errorf("expected upgrade %s to be already applied, got %+v", version, pending)
+	}
+}
+
+func TestScheduledUpgrade(t *testing.T) {
+	cfg := &types.Config{Home: os.TempDir(), Name: "test-runnerd"}
+	cases := map[string]struct {
+		upgrade   govTypes.Upgrade
+		expectErr bool
+		expect    string
+	}{
+		"scheduled":      {upgrade: govTypes.NewUpgrade(100, "RC-0.2.0"), expect: "RC-0.2.0"},
+		"nothing":        {upgrade: govTypes.Upgrade{}},
+		"path traversal": {upgrade: govTypes.NewUpgrade(100, "../x"), expectErr: true},
+		"separator":      {upgrade: govTypes.NewUpgrade(100, "a/b"), expectErr: true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			upgrade, err := scheduledUpgrade(cfg, tc.upgrade)
+			if tc.expectErr {
+				if errors.Cause(err) != types.ErrMalformedUpgrade {
+					t.Errorf("expected ErrMalformedUpgrade, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+			got := ""
+			if upgrade != nil {
+				got = upgrade.Name
+			}
+			if got != tc.expect {
+				t.Errorf("expected upgrade %q, got %q", tc.expect, got)
+			}
+		})
+	}
+}
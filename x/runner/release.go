@@ -0,0 +1,121 @@
+package runner
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/pokt-network/pocket-runner/internal/types"
+)
+
+const releaseDir = "release"
+
+// ErrBinaryNotInArchive occurs when a release archive does not hold the daemon binary
+var ErrBinaryNotInArchive = errors.New("binary not found in archive")
+
+// DownloadRelease downloads the prebuilt release of the upgrade for this OS and architecture,
+// verifies it and extracts the daemon binary to cfg.UpgradeBin. The release mirrors are tried in order
+// until one of them serves a verified release holding the binary.
+func DownloadRelease(ctx context.Context, cfg *types.Config, info *types.UpgradeInfo) error {
+	mirrors := cfg.Download.ReleaseMirrors
+	if len(mirrors) == 0 {
+		return errors.New("no release mirror is set")
+	}
+	for _, mirror := range mirrors {
+		err := downloadReleaseFrom(ctx, cfg, info, mirror)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		log.Printf("could not install the release of %s from %s: %v\n", info.Name, mirror.URL, err)
+	}
+	return errors.Errorf("could not install a verified release of %s from %d mirrors", info.Name, len(mirrors))
+}
+
+// downloadReleaseFrom installs the release of the upgrade served by mirror.
+// An interrupted download is left in the release dir, so the next attempt resumes it.
+func downloadReleaseFrom(ctx context.Context, cfg *types.Config, info *types.UpgradeInfo, mirror types.Mirror) error {
+	link := mirror.Link(cfg.Name, info.Name)
+	u, err := url.Parse(link)
+	if err != nil {
+		return errors.Wrapf(err, "invalid release url %s", link)
+	}
+	dir := filepath.Join(cfg.UpgradeDir(info.Name), releaseDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrap(err, "cannot create release dir")
+	}
+	archive := filepath.Join(dir, path.Base(u.Path))
+
+	log.Printf("downloading release %s\n", link)
+	if err := Download(ctx, link, archive, downloadOptions(cfg, mirror)); err != nil {
+		// keep a partial download to resume it, but not an empty dir
+		os.Remove(dir)
+		return err
+	}
+	defer os.RemoveAll(dir)
+	// nothing is extracted or executed unless it is the expected artifact
+	if err := VerifyArtifact(cfg, info, link, archive); err != nil {
+		return err
+	}
+	if err := ExtractBinary(archive, cfg.Name, cfg.UpgradeBin(info.Name)); err != nil {
+		return err
+	}
+	return types.CheckBinary(cfg.UpgradeBin(info.Name))
+}
+
+// ExtractBinary copies the file named name out of the zip, tar.gz or tar.xz archive to dst and makes it executable
+func ExtractBinary(archive, name, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return errors.Wrapf(err, "cannot create %s", filepath.Dir(dst))
+	}
+	// extract next to dst and rename, so a partial binary is never left at dst
+	tmp, err := ioutil.TempFile(filepath.Dir(dst), "."+filepath.Base(dst))
+	if err != nil {
+		return errors.Wrap(err, "cannot create temporary binary")
+	}
+	defer os.Remove(tmp.Name())
+
+	err = extractFile(archive, name, tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrapf(err, "cannot extract %s from %s", name, archive)
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return errors.Wrapf(err, "cannot make %s executable", dst)
+	}
+	return errors.Wrapf(os.Rename(tmp.Name(), dst), "cannot install %s", dst)
+}
+
+// extractFile writes the regular file named name of archive to w, the file may be in any directory of the archive
+func extractFile(archive, name string, w io.Writer) error {
+	found := false
+	err := walkArchive(archive, func(entry archiveEntry) error {
+		if !entry.mode.IsRegular() || entry.hardlink || path.Base(entry.name) != name {
+			return nil
+		}
+		rc, err := entry.open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		if _, err := io.Copy(w, rc); err != nil {
+			return err
+		}
+		found = true
+		return errStopWalk
+	})
+	if err == nil && !found {
+		return ErrBinaryNotInArchive
+	}
+	return err
+}
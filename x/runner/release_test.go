@@ -0,0 +1,110 @@
+package runner
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pokt-network/pocket-runner/internal/types"
+)
+
+const testBinary = "#!/bin/sh\necho upgraded\n"
+
+// testArchive returns a zip, tar.gz or tar.xz holding a README and the file name in a sub directory
+func testArchive(t *testing.T, format, name, content string) []byte {
+	return buildArchive(t, format, []testEntry{
+		{name: "release/README.md", mode: 0644, content: "pocket-core"},
+		{name: "release/" + name, mode: 0644, content: content},
+	})
+}
+
+func TestDownloadRelease(t *testing.T) {
+	for _, format := range []string{"tar.gz", "tar.xz", "zip"} {
+		t.Run(format, func(t *testing.T) {
+			home, err := copyTestData("validate")
+			if err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+			defer os.RemoveAll(home)
+
+			asset := "test-runnerd_RC-0.3.0_" + runtime.GOOS + "_" + runtime.GOARCH + "." + format
+			archive := testArchive(t, format, "test-runnerd", testBinary)
+			files := map[string][]byte{
+				"/RC-0.3.0/" + asset:   archive,
+				"/RC-0.3.0/SHA256SUMS": []byte(sha256Hex(archive) + "  " + asset + "\n"),
+			}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasPrefix(r.URL.Path, "/broken/") {
+					http.Error(w, "cache unavailable", http.StatusInternalServerError)
+					return
+				}
+				bz, ok := files[r.URL.Path]
+				if !ok {
+					http.NotFound(w, r)
+					return
+				}
+				_, _ = w.Write(bz)
+			}))
+			defer server.Close()
+
+			cfg := &types.Config{Home: home, Name: "test-runnerd", Download: types.DownloadPolicy{
+				// the first mirror fails with an http error, the runner fails over to the second one
+				ReleaseMirrors: []types.Mirror{
+					{URL: server.URL + "/broken/{version}/{name}_{version}_{os}_{arch}." + format, Timeout: time.Second},
+					{URL: server.URL + "/{version}/{name}_{version}_{os}_{arch}." + format, Timeout: time.Second},
+				},
+				SkipCompile: true,
+			}}
+			info := &types.UpgradeInfo{Name: "RC-0.3.0", Version: "RC-0.3.0"}
+			if err := DownloadBinary(context.Background(), cfg, info); err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+			if err := types.CheckBinary(cfg.UpgradeBin(info.Name)); err != nil {
+				t.Error(err)
+			}
+			if bz, err := ioutil.ReadFile(cfg.UpgradeBin(info.Name)); err != nil || string(bz) != testBinary {
+				t.Errorf("unexpected binary %q %v", bz, err)
+			}
+			// the downloaded archive is not kept around
+			if _, err := os.Stat(filepath.Join(cfg.UpgradeDir(info.Name), releaseDir)); !os.IsNotExist(err) {
+				t.Errorf("expected the release dir to be removed, got %v", err)
+			}
+
+			// a release that is not published fails without falling back to compiling
+			if err := DownloadBinary(context.Background(), cfg, &types.UpgradeInfo{Name: "RC-0.3.1", Version: "RC-0.3.1"}); err == nil {
+				t.Error("expected an error for a missing release")
+			}
+		})
+	}
+}
+
+func TestExtractBinaryMissing(t *testing.T) {
+	dir, err := ioutil.TempDir("", "release")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	archive := filepath.Join(dir, "release.tar.gz")
+	if err := ioutil.WriteFile(archive, testArchive(t, "tar.gz", "other-binary", testBinary), 0644); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	dst := filepath.Join(dir, "bin", "test-runnerd")
+	if err := ExtractBinary(archive, "test-runnerd", dst); errors.Cause(err) != ErrBinaryNotInArchive {
+		t.Errorf("expected ErrBinaryNotInArchive, got %v", err)
+	}
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Errorf("no binary must be left behind, got %v", err)
+	}
+}
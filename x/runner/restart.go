@@ -0,0 +1,126 @@
+package runner
+
+import (
+	"context"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pokt-network/pocket-runner/internal/types"
+)
+
+// RestartReason is why pocket-core needs to be relaunched
+type RestartReason int
+
+const (
+	// ReasonUpgrade the current binary was switched by an upgrade
+	ReasonUpgrade RestartReason = iota
+	// ReasonFailure pocket-core exited with a non zero status or was killed by a signal
+	ReasonFailure
+	// ReasonExit pocket-core exited cleanly
+	ReasonExit
+)
+
+func (r RestartReason) String() string {
+	switch r {
+	case ReasonUpgrade:
+		return "upgrade"
+	case ReasonFailure:
+		return "failure"
+	case ReasonExit:
+		return "exit"
+	}
+	return "unknown"
+}
+
+var (
+	// ErrRestartDisabled occurs when the restart policy does not allow a relaunch for the given reason
+	ErrRestartDisabled = errors.New("restart policy does not allow a restart")
+	// ErrRestartBudgetExceeded occurs when pocket-core failed more times than the policy allows
+	ErrRestartBudgetExceeded = errors.New("maximum number of restarts reached")
+)
+
+// Restarter decides whether pocket-core may be relaunched and how long to wait before doing so.
+// Upgrades are planned restarts, they are never delayed nor counted against the restart budget.
+type Restarter struct {
+	policy   types.RestartPolicy
+	restarts int
+	backoff  time.Duration
+	mu       sync.Mutex
+}
+
+// NewRestarter returns a restart engine for the given policy
+func NewRestarter(policy types.RestartPolicy) *Restarter {
+	return &Restarter{policy: policy}
+}
+
+// Allows reports whether the policy allows a restart for the given reason
+func (r *Restarter) Allows(reason RestartReason) bool {
+	switch r.policy.Mode {
+	case types.RestartModeNever:
+		return false
+	case types.RestartModeOnFailure:
+		return reason != ReasonExit
+	case types.RestartModeAlways:
+		return true
+	default: // after-upgrade is the default
+		return reason == ReasonUpgrade
+	}
+}
+
+// Next returns how long to wait before relaunching pocket-core, or an error if it must not be relaunched
+func (r *Restarter) Next(reason RestartReason) (time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.Allows(reason) {
+		return 0, errors.Wrapf(ErrRestartDisabled, "policy %q, reason %s", r.policy.Mode, reason)
+	}
+	if reason == ReasonUpgrade {
+		return 0, nil
+	}
+	if r.policy.MaxRestarts > 0 && r.restarts >= r.policy.MaxRestarts {
+		return 0, errors.Wrapf(ErrRestartBudgetExceeded, "%d restarts", r.restarts)
+	}
+	r.restarts++
+	delay := r.backoff
+	if delay == 0 {
+		delay = r.policy.Backoff
+	}
+	r.backoff = delay * 2
+	if r.policy.MaxBackoff > 0 && r.backoff > r.policy.MaxBackoff {
+		r.backoff = r.policy.MaxBackoff
+	}
+	return delay, nil
+}
+
+// Restarts returns the number of failure restarts performed since the last Reset
+func (r *Restarter) Restarts() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.restarts
+}
+
+// Reset clears the restart counter and the backoff, call it once pocket-core is healthy again
+func (r *Restarter) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.restarts = 0
+	r.backoff = 0
+}
+
+// Switch halts pocket-core through manager, calls change while it is stopped and relaunches it if the policy allows
+// relaunching after an upgrade. Nothing else starts pocket-core in between, see ProcessManager.Switch.
+func (r *Restarter) Switch(ctx context.Context, manager *ProcessManager, change func() error) (*exec.Cmd, error) {
+	return manager.Switch(func() error {
+		if err := change(); err != nil {
+			return err
+		}
+		// upgrades are never delayed
+		if _, err := r.Next(ReasonUpgrade); err != nil {
+			return err
+		}
+		// the runner may have been stopped while pocket-core was
+		return ctx.Err()
+	})
+}
@@ -0,0 +1,72 @@
+package runner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pokt-network/pocket-runner/internal/types"
+)
+
+func TestRestarterAllows(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    types.RestartMode
+		upgrade bool
+		failure bool
+		exit    bool
+	}{
+		{name: "default", mode: "", upgrade: true, failure: false, exit: false},
+		{name: "never", mode: types.RestartModeNever, upgrade: false, failure: false, exit: false},
+		{name: "after-upgrade", mode: types.RestartModeAfterUpgrade, upgrade: true, failure: false, exit: false},
+		{name: "on-failure", mode: types.RestartModeOnFailure, upgrade: true, failure: true, exit: false},
+		{name: "always", mode: types.RestartModeAlways, upgrade: true, failure: true, exit: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRestarter(types.RestartPolicy{Mode: tt.mode})
+			if got := r.Allows(ReasonUpgrade); got != tt.upgrade {
+				t.Errorf("Allows(upgrade) = %v, want %v", got, tt.upgrade)
+			}
+			if got := r.Allows(ReasonFailure); got != tt.failure {
+				t.Errorf("Allows(failure) = %v, want %v", got, tt.failure)
+			}
+			if got := r.Allows(ReasonExit); got != tt.exit {
+				t.Errorf("Allows(exit) = %v, want %v", got, tt.exit)
+			}
+		})
+	}
+}
+
+func TestRestarterBackoff(t *testing.T) {
+	r := NewRestarter(types.RestartPolicy{
+		Mode:        types.RestartModeOnFailure,
+		Backoff:     time.Second,
+		MaxBackoff:  3 * time.Second,
+		MaxRestarts: 4,
+	})
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second} {
+		got, err := r.Next(ReasonFailure)
+		if err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		if got != want {
+			t.Errorf("restart %d: delay = %s, want %s", i, got, want)
+		}
+	}
+	// upgrades are neither delayed nor limited by the budget
+	if delay, err := r.Next(ReasonUpgrade); err != nil || delay != 0 {
+		t.Errorf("Next(upgrade) = %s, %v", delay, err)
+	}
+	if _, err := r.Next(ReasonFailure); errors.Cause(err) != ErrRestartBudgetExceeded {
+		t.Errorf("expected budget to be exceeded, got %v", err)
+	}
+	r.Reset()
+	if delay, err := r.Next(ReasonFailure); err != nil || delay != time.Second {
+		t.Errorf("Next(failure) after reset = %s, %v", delay, err)
+	}
+	if _, err := r.Next(ReasonExit); errors.Cause(err) != ErrRestartDisabled {
+		t.Errorf("expected restart on clean exit to be disabled, got %v", err)
+	}
+}
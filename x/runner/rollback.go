@@ -0,0 +1,89 @@
+package runner
+
+import (
+	"context"
+	"log"
+	"os/exec"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pokt-network/pocket-runner/internal/types"
+)
+
+var (
+	// ErrUpgradeCrashed occurs when the upgraded binary exits during the health window
+	ErrUpgradeCrashed = errors.New("upgraded pocket-core exited")
+	// ErrUpgradeStalled occurs when the upgraded binary produces no block during the health window
+	ErrUpgradeStalled = errors.New("upgraded pocket-core did not produce a block")
+)
+
+// VerifyUpgrade watches cmd, the upgraded pocket-core, during the health window of the rollback policy.
+// It returns nil once pocket-core produced a block past the upgrade height and past switched, the height reached
+// before switching binaries, an error if the process exited or no block arrived in time.
+// Exits of cmd are left to VerifyUpgrade until it returns.
+func VerifyUpgrade(ctx context.Context, cfg *types.Config, supervisor *Supervisor, cmd *exec.Cmd, upgrade *types.UpgradeInfo, switched int64) error {
+	// the switch may happen past the upgrade height, blocks the previous binary produced prove nothing
+	past := upgrade.Height
+	if switched > past {
+		past = switched
+	}
+	window := cfg.Rollback.WithDefaults().Window
+	interval := cfg.Readiness.WithDefaults().Interval
+	// the restart policy must not relaunch the upgraded binary behind our back
+	supervisor.Expect(cmd)
+	done := supervisor.Done(cmd)
+	deadline := time.NewTimer(window)
+	defer deadline.Stop()
+
+	log.Printf("verifying upgrade %s for %s\n", upgrade.Name, window)
+	for {
+		if height, err := LatestHeight(cfg); err == nil && height > past {
+			supervisor.Unexpect(cmd)
+			select {
+			case <-done: // exited before it was handed back to the restart policy
+				return errors.Wrapf(ErrUpgradeCrashed, "upgrade %s", upgrade.Name)
+			default:
+			}
+			log.Printf("upgrade %s is healthy at height %d\n", upgrade.Name, height)
+			return nil
+		}
+		select {
+		case <-done:
+			if ctx.Err() != nil {
+				return ctx.Err() // the runner stopped it
+			}
+			return errors.Wrapf(ErrUpgradeCrashed, "upgrade %s exited within %s", upgrade.Name, window)
+		case <-deadline.C:
+			return errors.Wrapf(ErrUpgradeStalled, "upgrade %s produced no block past height %d within %s", upgrade.Name, past, window)
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Rollback points the current link back at the binary record replaced and, if the rollback policy
+// asks for it, restores the data backed up before the upgrade. pocket-core must not be running.
+func Rollback(cfg *types.Config, record types.UpgradeRecord) error {
+	point := record.Rollback
+	if point == nil {
+		return errors.Errorf("upgrade %s cannot be rolled back, nothing was recorded about the previous binary", record.Name)
+	}
+	var err error
+	if point.Previous == "" {
+		err = cfg.SetCurrentGenesis()
+	} else {
+		err = cfg.SetCurrentUpgrade(point.Previous)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "could not restore the binary replaced by upgrade %s", record.Name)
+	}
+	if !cfg.Rollback.RestoreData {
+		return nil
+	}
+	if point.Backup == "" {
+		log.Printf("no data backup was taken before upgrade %s, keeping the current data\n", record.Name)
+		return nil
+	}
+	return RestoreBackup(cfg, point.Backup, record.Name)
+}
@@ -0,0 +1,119 @@
+package runner
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pokt-network/pocket-runner/internal/types"
+)
+
+func TestRollback(t *testing.T) {
+	home, err := copyTestData("validate")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(home)
+	cfg := &types.Config{Home: home, Name: "test-runnerd", Rollback: types.RollbackPolicy{RestoreData: true}}
+	state := filepath.Join(cfg.DataDir(), "state.db")
+	if err := os.MkdirAll(cfg.DataDir(), 0755); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err := ioutil.WriteFile(state, []byte("before"), 0644); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	upgrade := &types.UpgradeInfo{Name: "RC-0.2.0", Version: "RC-0.2.0", Height: 10}
+	point, err := Switch(cfg, upgrade)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if point.Previous != "" || point.Backup != cfg.BackupDir(upgrade) {
+		t.Errorf("unexpected rollback point %+v", point)
+	}
+	// the failed migration changed the data
+	if err := ioutil.WriteFile(state, []byte("after"), 0644); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	if err := Rollback(cfg, types.UpgradeRecord{Name: upgrade.Name, Height: upgrade.Height, Rollback: &point}); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	currentBin, err := cfg.CurrentBin()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if currentBin != cfg.GenesisBin() {
+		t.Errorf("current bin %s was not rolled back to genesis", currentBin)
+	}
+	if bz, err := ioutil.ReadFile(state); err != nil || string(bz) != "before" {
+		t.Errorf("data was not restored: %q %v", bz, err)
+	}
+	if bz, err := ioutil.ReadFile(filepath.Join(cfg.DataDir()+".RC-0.2.0-failed", "state.db")); err != nil || string(bz) != "after" {
+		t.Errorf("the data of the failed upgrade was not kept: %q %v", bz, err)
+	}
+
+	if err := Rollback(cfg, types.UpgradeRecord{Name: upgrade.Name}); err == nil {
+		t.Error("expected an error without a rollback point")
+	}
+}
+
+func TestVerifyUpgrade(t *testing.T) {
+	upgrade := &types.UpgradeInfo{Name: "RC-0.2.0", Height: 10}
+	cases := map[string]struct {
+		script    string
+		height    int64
+		switched  int64
+		expectErr error
+	}{
+		"healthy": {script: "sleep 10", height: 11},
+		"crashed": {script: "exit 1", height: 10, expectErr: ErrUpgradeCrashed},
+		"stalled": {script: "sleep 10", height: 10, expectErr: ErrUpgradeStalled},
+		"behind":  {script: "sleep 10", height: 9, expectErr: ErrUpgradeStalled},
+		// the previous binary already produced block 12 before the switch
+		"switched past the upgrade height": {script: "sleep 10", height: 12, switched: 12, expectErr: ErrUpgradeStalled},
+		"progress past the switch":         {script: "sleep 10", height: 13, switched: 12},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			server, port := newStatusServer(t, 0, tc.height)
+			defer server.Close()
+			cfg := &types.Config{
+				Name:      "test-runnerd",
+				Port:      port,
+				Readiness: types.ReadinessProbe{Interval: 10 * time.Millisecond},
+				Rollback:  types.RollbackPolicy{Window: 300 * time.Millisecond},
+			}
+			supervisor := NewSupervisor()
+			cmd := exec.Command("sh", "-c", tc.script)
+			if err := cmd.Start(); err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+			defer cmd.Process.Kill()
+			supervisor.Watch(cmd)
+
+			err := VerifyUpgrade(context.Background(), cfg, supervisor, cmd, upgrade, tc.switched)
+			if errors.Cause(err) != tc.expectErr {
+				t.Errorf("expected %v, got %v", tc.expectErr, err)
+			}
+			if tc.expectErr == ErrUpgradeCrashed {
+				if evt := <-supervisor.Exits(); !evt.Expected {
+					t.Errorf("the crash must be left to VerifyUpgrade: %s", evt)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,421 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pokt-network/pocket-runner/internal/types"
+	tmTypes "github.com/tendermint/tendermint/types"
+)
+
+var (
+	// ErrAlreadyStarted occurs when a Runner is started twice
+	ErrAlreadyStarted = errors.New("runner was already started")
+	// ErrNotStarted occurs when a Runner is stopped or waited for before it was started
+	ErrNotStarted = errors.New("runner was not started")
+)
+
+// ExitError occurs when pocket-core exited and the restart policy does not relaunch it
+type ExitError struct {
+	Exit ExitEvent
+	// Err is why pocket-core is not relaunched
+	Err error
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("pocket-core %s, not relaunching it: %v", e.Exit, e.Err)
+}
+
+// EventKind tells what an Event is about
+type EventKind int
+
+const (
+	// EventExit pocket-core exited
+	EventExit EventKind = iota
+	// EventStage the upgrade state machine moved to another stage
+	EventStage
+	// EventConnection the connection of the event listener to pocket-core changed
+	EventConnection
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventExit:
+		return "exit"
+	case EventStage:
+		return "stage"
+	case EventConnection:
+		return "connection"
+	}
+	return "unknown"
+}
+
+// Event is something that happened to the pocket-core supervised by a Runner, only the field of its Kind is set
+type Event struct {
+	Kind       EventKind
+	Time       time.Time
+	Exit       ExitEvent
+	Transition Transition
+	Connection ConnectionState
+}
+
+func (e Event) String() string {
+	switch e.Kind {
+	case EventExit:
+		return fmt.Sprintf("pocket-core %s", e.Exit)
+	case EventStage:
+		return e.Transition.String()
+	case EventConnection:
+		return fmt.Sprintf("event listener %s", e.Connection)
+	}
+	return e.Kind.String()
+}
+
+// Runner runs pocket-core, relaunches it as its restart policy allows and applies the upgrades scheduled on chain.
+// It is what the pocket-runner binary runs, and it never exits the process: every error is returned, so it can be
+// embedded in other programs. A Runner is started once, it stops when its context is done, when Stop is called,
+// or when it cannot go on, and pocket-core is never left running once it stopped.
+type Runner struct {
+	cfg    *types.Config
+	args   []string
+	stdout io.Writer
+	stderr io.Writer
+	events chan Event
+	// errs holds the first error the runner cannot go on with
+	errs chan error
+	done chan struct{}
+	err  error
+	// mu guards started and cancel
+	mu         sync.Mutex
+	started    bool
+	cancel     context.CancelFunc
+	passphrase *Passphrase
+	manager    *ProcessManager
+	restarts   *Restarter
+	prefetcher *Prefetcher
+	listener   *EventListener
+	machine    *UpgradeMachine
+}
+
+// NewRunner validates cfg and returns a runner launching its current binary with args, nothing is started yet.
+// The output of pocket-core goes to stdout and stderr.
+func NewRunner(cfg *types.Config, args []string, stdout, stderr io.Writer) (*Runner, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &Runner{
+		cfg:    cfg,
+		args:   args,
+		stdout: stdout,
+		stderr: stderr,
+		events: make(chan Event, eventBufferSize),
+		errs:   make(chan error, 1),
+		done:   make(chan struct{}),
+	}, nil
+}
+
+// Events is where everything that happens to pocket-core is sent, it is closed once the runner stopped.
+// Events are dropped while the channel is full, so nobody has to read it.
+func (r *Runner) Events() <-chan Event {
+	return r.events
+}
+
+// Start launches pocket-core and starts applying upgrades, it returns once the rpc of pocket-core answers and
+// the event listener subscribed, see NewEventListener. If it fails pocket-core is stopped again.
+// The runner stops, and stops pocket-core, once ctx is done.
+func (r *Runner) Start(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.started {
+		return ErrAlreadyStarted
+	}
+	state, err := types.LoadUpgradeState(r.cfg.UpgradeStateFile())
+	if err != nil {
+		return err
+	}
+	// the passphrase is read once here, the env, fd and prompt sources cannot be read again for the relaunches
+	passphrase, err := NewPassphrase(r.cfg.Passphrase)
+	if err != nil {
+		return err
+	}
+	// every launch, stop and signal of pocket-core goes through the manager, it owns the process
+	manager := NewProcessManager(r.cfg, r.args, r.stdout, r.stderr, passphrase)
+	if _, err := manager.Start(); err != nil {
+		passphrase.Wipe()
+		return err
+	}
+	listener, err := NewEventListener(r.cfg)
+	if err != nil {
+		passphrase.Wipe()
+		if stopErr := manager.Stop(); stopErr != nil {
+			log.Printf("%+v\n", stopErr)
+		}
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	r.started, r.cancel = true, cancel
+	r.passphrase, r.manager, r.listener = passphrase, manager, listener
+	r.restarts = NewRestarter(r.cfg.RestartPolicy)
+	// binaries are acquired in the background while pocket-core keeps running
+	r.prefetcher = NewPrefetcher(ctx, r.cfg)
+	// the machine is the only one applying upgrades, the listener events are handed to it in order
+	r.machine = NewUpgradeMachine(r.cfg, manager, r.restarts, r.prefetcher, state, types.NewUpgradePlan(ResumeUpgrade(r.cfg, state)...))
+	upgrades := make(chan *types.UpgradeInfo, 1)
+	log.Println("starting listeners")
+	go func() {
+		if err := r.machine.Run(ctx); err != nil {
+			r.fail(err)
+		}
+	}()
+	go WaitForUpgrade(ctx, listener, upgrades)
+	go WaitForBlockHeight(ctx, r.machine, listener, upgrades)
+	go r.run(ctx)
+	return nil
+}
+
+// Stop gracefully stops pocket-core and the runner, it returns once they stopped with the error Wait returns
+func (r *Runner) Stop() error {
+	r.mu.Lock()
+	cancel := r.cancel
+	r.mu.Unlock()
+	if cancel == nil {
+		return ErrNotStarted
+	}
+	cancel()
+	return r.Wait()
+}
+
+// Wait blocks until the runner stopped and returns why: nil once it was stopped, an *ExitError when pocket-core
+// exited and was not relaunched, ErrRestartDisabled when an upgrade was applied and the restart policy leaves
+// starting it to someone else, any other error when the runner could not go on.
+func (r *Runner) Wait() error {
+	r.mu.Lock()
+	started := r.started
+	r.mu.Unlock()
+	if !started {
+		return ErrNotStarted
+	}
+	<-r.done
+	return r.err
+}
+
+// run reacts to the exits of pocket-core until the runner stops
+func (r *Runner) run(ctx context.Context) {
+	defer close(r.done)
+	defer close(r.events)
+	log.Println("Loop is begining!")
+	for {
+		select {
+		case err := <-r.errs:
+			r.shutdown(err)
+			return
+		case state := <-r.listener.States():
+			log.Printf("event listener %s\n", state)
+			r.publish(Event{Kind: EventConnection, Connection: state})
+		case transition := <-r.machine.Transitions():
+			r.publish(Event{Kind: EventStage, Transition: transition})
+		case exit := <-r.manager.Exits():
+			r.publish(Event{Kind: EventExit, Exit: exit})
+			if err := r.exited(ctx, exit); err != nil {
+				r.shutdown(err)
+				return
+			}
+		case <-ctx.Done():
+			r.shutdown(nil)
+			return
+		}
+	}
+}
+
+// exited handles an exit of pocket-core, it returns an *ExitError when the restart policy does not relaunch it
+func (r *Runner) exited(ctx context.Context, exit ExitEvent) error {
+	if exit.Expected || exit.Cmd != r.manager.Current() {
+		return nil
+	}
+	log.Printf("pocket-core %s\n", exit)
+	if r.machine.AtUpgradeHeight() {
+		// governance halted pocket-core, relaunching the old binary would halt again at the same height
+		log.Printf("applying the upgrade pocket-core halted for\n")
+		go func() {
+			_ = r.machine.Halt(ctx)
+		}()
+		return nil
+	}
+	if r.machine.Upgrading() {
+		// the upgrade relaunches pocket-core, the restart policy is not consulted
+		log.Printf("leaving the relaunch of pocket-core to the upgrade in progress\n")
+		return nil
+	}
+	delay, err := r.restarts.Next(exit.Reason())
+	if err != nil {
+		return &ExitError{Exit: exit, Err: err}
+	}
+	go r.relaunch(ctx, delay)
+	return nil
+}
+
+// shutdown stops everything the runner started, pocket-core last, and records err as the reason the runner stopped
+func (r *Runner) shutdown(err error) {
+	r.cancel()
+	r.prefetcher.Stop()
+	r.listener.Stop()
+	r.passphrase.Wipe()
+	// no relaunch in flight may start pocket-core again
+	if stopErr := r.manager.Close(); stopErr != nil {
+		if err == nil {
+			err = stopErr
+		} else {
+			log.Printf("%+v\n", stopErr)
+		}
+	}
+	r.err = err
+}
+
+// fail stops the runner with err, unless it is stopping already
+func (r *Runner) fail(err error) {
+	select {
+	case r.errs <- err:
+	default:
+	}
+}
+
+// publish sends evt to Events unless the channel is full
+func (r *Runner) publish(evt Event) {
+	evt.Time = time.Now()
+	select {
+	case r.events <- evt:
+	default:
+	}
+}
+
+// relaunch starts pocket-core again once delay has elapsed
+func (r *Runner) relaunch(ctx context.Context, delay time.Duration) {
+	if delay > 0 {
+		log.Printf("relaunching pocket-core in %s\n", delay)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+	}
+	if r.machine.Upgrading() {
+		log.Printf("not relaunching pocket-core, an upgrade is in progress\n")
+		return
+	}
+	_, err := r.manager.Start()
+	if errors.Cause(err) == ErrAlreadyRunning {
+		// an upgrade or a rollback relaunched pocket-core in the meantime
+		log.Printf("not relaunching pocket-core: %v\n", err)
+		return
+	}
+	if err == ErrManagerClosed {
+		return // the runner stopped in the meantime
+	}
+	if err != nil {
+		r.fail(err)
+	}
+}
+
+// WaitForBlockHeight hands the upgrades it receives and the height of every block header to machine, which applies the upgrades.
+func WaitForBlockHeight(ctx context.Context, machine *UpgradeMachine, listener *EventListener, upgrades chan *types.UpgradeInfo) {
+	log.Printf("\n *****Listen For BlockHeight***** \n")
+	for {
+		var err error
+		select {
+		case scheduled := <-upgrades:
+			err = machine.Schedule(ctx, scheduled)
+		case rawHeaderEvt := <-listener.HeaderChan:
+			headerEvt, ok := rawHeaderEvt.Data.(tmTypes.EventDataNewBlockHeader)
+			if !ok {
+				log.Printf("ignoring header event of type %T\n", rawHeaderEvt.Data)
+				continue
+			}
+			log.Printf("\n *****Received Block Header for Height %v ***** \n", headerEvt.Header.Height)
+			// a header may be missed while the listener reconnects, the machine applies upgrades past due right away
+			err = machine.Height(ctx, headerEvt.Header.Height)
+		case <-ctx.Done():
+			return
+		}
+		if err != nil {
+			return // the machine only refuses events once ctx is done
+		}
+	}
+}
+
+// ResumeUpgrade combines the persisted upgrade state with the upgrade currently scheduled on chain,
+// returns the upgrades to wait for ordered by height
+func ResumeUpgrade(cfg *types.Config, state *types.UpgradeState) []*types.UpgradeInfo {
+	current := cfg.CurrentUpgrade()
+	for _, pending := range state.PendingUpgrades() {
+		if pending.Name != current {
+			continue
+		}
+		// the runner stopped after switching binaries but before recording it
+		if err := state.MarkApplied(pending); err != nil {
+			log.Printf("could not save the upgrade state: %v\n", err)
+		}
+	}
+	scheduled, err := PendingUpgrade(cfg, TMClient(cfg.RPCAddress()))
+	if err != nil {
+		log.Printf("could not query the scheduled upgrade, resuming from %s: %v\n", cfg.UpgradeStateFile(), err)
+		return state.PendingUpgrades()
+	}
+	return reconcileUpgrades(state, scheduled)
+}
+
+// reconcileUpgrades makes the pending upgrades of state match scheduled, the upgrade scheduled on chain or nil if there is none.
+// Governance holds a single scheduled upgrade, the pending upgrades that differ from it were replaced or cancelled while the runner was down.
+func reconcileUpgrades(state *types.UpgradeState, scheduled *types.UpgradeInfo) []*types.UpgradeInfo {
+	for _, pending := range state.PendingUpgrades() {
+		if scheduled != nil && pending.Name == scheduled.Name && pending.Height == scheduled.Height {
+			continue
+		}
+		log.Printf("upgrade %s at height %d is no longer scheduled on chain, cancelling it\n", pending.Name, pending.Height)
+		if err := state.Cancel(pending.Name); err != nil {
+			log.Printf("could not save the upgrade state: %v\n", err)
+		}
+	}
+	if scheduled != nil && state.RolledBack(scheduled) {
+		log.Printf("upgrade %s at height %d was rolled back before, not applying it again\n", scheduled.Name, scheduled.Height)
+	} else if scheduled != nil {
+		if err := state.Schedule(scheduled); err != nil {
+			log.Printf("could not save the upgrade state: %v\n", err)
+		}
+	}
+	return state.PendingUpgrades()
+}
+
+// WaitForUpgrade listens transactions and filters upgrades, passess them to the upgrade channel.
+func WaitForUpgrade(ctx context.Context, listener *EventListener, upgrades chan *types.UpgradeInfo) {
+	log.Printf("\n *****Wait for Upgrade***** \n")
+	for {
+		upgrade := &types.UpgradeInfo{}
+		select {
+		case rawTxEvt := <-listener.TxChan:
+			log.Printf("\n *****Received a Tx***** \n")
+			if len(rawTxEvt.Events["upgrade.action"]) == 1 {
+				log.Printf("\n *****Received an Upgrade***** \n")
+				if err := upgrade.SetUpgrade(rawTxEvt.Events["upgrade.action"][0]); err != nil {
+					log.Printf("ignoring upgrade tx: %v\n", err)
+					continue
+				}
+				if hashes := rawTxEvt.Events["tx.hash"]; len(hashes) > 0 {
+					upgrade.TxHash = hashes[0]
+				}
+				select {
+				case upgrades <- upgrade:
+					log.Printf("\n *****Sent an Upgrade***** \n")
+				case <-ctx.Done():
+					return
+				}
+			}
+		case <-ctx.Done():
+			return // the machine only refuses events once ctx is done
+		}
+	}
+}
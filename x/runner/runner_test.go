@@ -0,0 +1,254 @@
+package runner
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pokt-network/pocket-runner/internal/types"
+	"github.com/pokt-network/posmint/x/gov"
+	govTypes "github.com/pokt-network/posmint/x/gov/types"
+	tmTypes "github.com/tendermint/tendermint/types"
+)
+
+func TestRunner(t *testing.T) {
+	home, err := copyTestData("validate")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(home)
+	if _, err := NewRunner(DefaultConfig(home, ""), nil, ioutil.Discard, ioutil.Discard); err == nil {
+		t.Error("expected an invalid config to be rejected")
+	}
+	cfg := DefaultConfig(home, "sleeper")
+	cfg.Port = "1" // pocket-core never answers
+	cfg.Readiness = ReadinessProbe{Timeout: 300 * time.Millisecond, Interval: 10 * time.Millisecond, Jitter: time.Millisecond}
+	if err := os.MkdirAll(filepath.Dir(cfg.GenesisBin()), 0755); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	pidFile := filepath.Join(home, "pid")
+	script := "#!/bin/sh\necho $$ > " + pidFile + "\ntrap 'exit 0' TERM\nwhile true; do sleep 0.1; done\n"
+	if err := ioutil.WriteFile(cfg.GenesisBin(), []byte(script), 0755); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	r, err := NewRunner(cfg, nil, ioutil.Discard, ioutil.Discard)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err := r.Stop(); err != ErrNotStarted {
+		t.Errorf("expected ErrNotStarted, got %v", err)
+	}
+	if err := r.Start(context.Background()); errors.Cause(err) != ErrNotReady {
+		t.Errorf("expected ErrNotReady, got %v", err)
+	}
+	if err := r.Wait(); err != ErrNotStarted {
+		t.Errorf("expected ErrNotStarted after a failed start, got %v", err)
+	}
+	// the pocket-core launched by the failed start was stopped
+	bz, err := ioutil.ReadFile(pidFile)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(bz)))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if err := syscall.Kill(pid, 0); err != syscall.ESRCH {
+		t.Errorf("pocket-core (pid %d) is still running: %v", pid, err)
+	}
+}
+
+func TestWaitForUpgrade(t *testing.T) {
+	t.Log("test is beggining")
+	resetTestACL() // the upgrade tx must be signed by this node's coinbase
+	_, kb, cleanup := NewInMemoryTendermintNode(t, oneValTwoNodeGenesisState())
+	cb, err := kb.GetCoinbase()
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	home, err := copyTestData("validate")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	cfg := &types.Config{Home: home, Name: "test-runnerd", Port: "36657"}
+	defer os.RemoveAll(home)
+	const version = "RC-0.2.0"
+
+	upgrades := make(chan *types.UpgradeInfo)
+
+	memCli, stopCli, evtChan := subscribeTo(t, tmTypes.EventNewBlock)
+
+	listener, err := NewEventListener(cfg)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	go WaitForUpgrade(ctx, listener, upgrades)
+
+	select {
+	case <-evtChan:
+		memCli, stopCli, evtChan = subscribeTo(t, tmTypes.EventNewBlockHeader)
+		tx, err := gov.UpgradeTx(memCodec(), memCli, kb, cb.GetAddress(), govTypes.Upgrade{
+			Height:  2,
+			Version: version,
+		}, "test")
+		if tx == nil {
+			t.Error(errors.New("tx is nil"))
+			t.FailNow()
+		}
+		if err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+	}
+	select {
+	case upgrade := <-upgrades: // NOTE this means the tx was intercepted & sent as a valid upgrade for the runner
+		if upgrade.Name != version {
+			t.Errorf("received upgrade: %s does not match expected %s", upgrade.Name, version)
+		}
+	case <-time.After(30 * time.Second):
+		t.Error("timed out waiting for the upgrade tx")
+	}
+	cancel()
+	stopCli()
+	listener.Stop()
+	cleanup()
+	t.Log("test ended")
+	return
+}
+
+func TestReconcileUpgrades(t *testing.T) {
+	first := &types.UpgradeInfo{Name: "RC-0.2.0", Version: "RC-0.2.0", Height: 10}
+	second := &types.UpgradeInfo{Name: "RC-0.2.1", Version: "RC-0.2.1", Height: 20}
+	cases := map[string]struct {
+		scheduled *types.UpgradeInfo
+		expect    []string
+	}{
+		"scheduled upgrade is kept":      {scheduled: second, expect: []string{second.Name}},
+		"nothing scheduled":              {expect: nil},
+		"rescheduled at another height":  {scheduled: &types.UpgradeInfo{Name: first.Name, Version: first.Version, Height: 30}, expect: []string{first.Name}},
+		"replaced by an unknown upgrade": {scheduled: &types.UpgradeInfo{Name: "RC-0.3.0", Version: "RC-0.3.0", Height: 40}, expect: []string{"RC-0.3.0"}},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "reconcile")
+			if err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+			defer os.RemoveAll(dir)
+			state, err := types.LoadUpgradeState(filepath.Join(dir, "upgrade-state.json"))
+			if err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+			for _, upgrade := range []*types.UpgradeInfo{first, second} {
+				if err := state.Schedule(upgrade); err != nil {
+					t.Error(err)
+					t.FailNow()
+				}
+			}
+			var got []string
+			for _, pending := range reconcileUpgrades(state, tc.scheduled) {
+				got = append(got, pending.Name)
+			}
+			if strings.Join(got, ",") != strings.Join(tc.expect, ",") {
+				t.Errorf("expected the pending upgrades %v, got %v", tc.expect, got)
+			}
+		})
+	}
+}
+
+func TestRunnerExitAtUpgradeHeight(t *testing.T) {
+	ctx := context.Background()
+	upgrade := &types.UpgradeInfo{Name: "RC-0.2.0", Version: "RC-0.2.0", Height: 10}
+	cases := map[string]struct {
+		height    int64
+		expectErr bool
+	}{
+		// governance halts pocket-core at the upgrade height, the block at that height never arrives
+		"halted for the upgrade": {height: upgrade.Height - 1},
+		// the after-upgrade policy does not relaunch pocket-core on other exits
+		"exit before the upgrade": {height: upgrade.Height - 5, expectErr: true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			machine, cleanup := newTestMachine(t, 11)
+			defer cleanup()
+			if err := os.MkdirAll(filepath.Dir(machine.cfg.UpgradeBin(upgrade.Name)), 0755); err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+			if err := ioutil.WriteFile(machine.cfg.UpgradeBin(upgrade.Name), []byte(sleeperScript), 0755); err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+			for _, evt := range []machineEvent{{kind: eventScheduled, upgrade: upgrade}, {kind: eventHeight, height: tc.height}} {
+				if err := machine.handle(ctx, evt); err != nil {
+					t.Error(err)
+					t.FailNow()
+				}
+			}
+			r := &Runner{cfg: machine.cfg, manager: machine.manager, restarts: machine.restarts, machine: machine}
+			if err := machine.manager.Signal(syscall.SIGINT); err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+			var exit ExitEvent
+			select {
+			case exit = <-machine.manager.Exits():
+			case <-time.After(5 * time.Second):
+				t.Error("timed out waiting for pocket-core to exit")
+				t.FailNow()
+			}
+			err := r.exited(ctx, exit)
+			if tc.expectErr {
+				if _, ok := err.(*ExitError); !ok {
+					t.Errorf("expected an *ExitError, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+			// the upgrade is applied instead of relaunching the binary that halted
+			select {
+			case evt := <-machine.events:
+				if err := machine.handle(ctx, evt); err != nil {
+					t.Error(err)
+					t.FailNow()
+				}
+			case <-time.After(5 * time.Second):
+				t.Error("timed out waiting for the halt to reach the machine")
+				t.FailNow()
+			}
+			if current := machine.cfg.CurrentUpgrade(); current != upgrade.Name || !machine.manager.Running() {
+				t.Errorf("expected pocket-core to be relaunched on %s, got %q", upgrade.Name, current)
+			}
+			if err := verified(t, ctx, machine); err != nil {
+				t.Error(err)
+			}
+			if stage, _ := machine.Stage(); stage != StageRunning {
+				t.Errorf("expected the upgrade to be running, got %s", stage)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package runner
+
+import (
+	"log"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pokt-network/pocket-runner/internal/types"
+)
+
+// killTimeout bounds the wait for a process to be reaped after it was killed
+var killTimeout = 10 * time.Second
+
+// ErrNotStopped occurs when pocket-core is still running after it was killed
+var ErrNotStopped = errors.New("pocket-core did not stop")
+
+// Stop gracefully stops cmd: the shutdown signal is sent first and pocket-core is killed only if it is
+// still running once the grace period has elapsed. The exit is expected, so it is not treated as a crash.
+// Stop returns once the process was reaped.
+func (s *Supervisor) Stop(cmd *exec.Cmd, policy types.ShutdownPolicy) error {
+	policy = policy.WithDefaults()
+	s.Expect(cmd)
+	done := s.watch(cmd)
+	pid := cmd.Process.Pid
+
+	if err := s.Signal(cmd, policy.Signal); err != nil && err != errReaped {
+		log.Printf("could not send %s to pocket-core (pid %d): %v\n", policy.Signal, pid, err)
+	}
+	select {
+	case <-done:
+		return nil
+	case <-time.After(policy.Grace):
+		log.Printf("pocket-core (pid %d) did not stop within %s of %s, killing it\n", pid, policy.Grace, policy.Signal)
+	}
+
+	if err := s.Signal(cmd, os.Kill); err != nil && err != errReaped {
+		log.Printf("could not kill pocket-core (pid %d): %v\n", pid, err)
+	}
+	select {
+	case <-done:
+		return nil
+	case <-time.After(killTimeout):
+		return errors.Wrapf(ErrNotStopped, "pid %d is still running %s after it was killed", pid, killTimeout)
+	}
+}
+
+// reaped reports whether done is closed
+func reaped(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,87 @@
+package runner
+
+import (
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/pokt-network/pocket-runner/internal/types"
+)
+
+func TestSupervisorStop(t *testing.T) {
+	cases := map[string]struct {
+		script       string
+		policy       types.ShutdownPolicy
+		expectSignal syscall.Signal
+		expectCode   int
+	}{
+		"exits on signal": {
+			script:     `trap "exit 0" TERM; while true; do sleep 0.1; done`,
+			policy:     types.ShutdownPolicy{Signal: syscall.SIGTERM, Grace: 5 * time.Second},
+			expectCode: 0,
+		},
+		"exits on interrupt": {
+			script:     `trap "exit 3" INT; while true; do sleep 0.1; done`,
+			policy:     types.ShutdownPolicy{Signal: syscall.SIGINT, Grace: 5 * time.Second},
+			expectCode: 3,
+		},
+		"killed after grace": {
+			script:       `trap "" TERM; while true; do sleep 0.1; done`,
+			policy:       types.ShutdownPolicy{Signal: syscall.SIGTERM, Grace: 300 * time.Millisecond},
+			expectSignal: syscall.SIGKILL,
+			expectCode:   -1,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			supervisor := NewSupervisor()
+			cmd := exec.Command("sh", "-c", tc.script)
+			if err := cmd.Start(); err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+			supervisor.Watch(cmd)
+			time.Sleep(100 * time.Millisecond) // let the shell install its traps
+			if err := supervisor.Stop(cmd, tc.policy); err != nil {
+				t.Error(err)
+				t.FailNow()
+			}
+			evt := <-supervisor.Exits()
+			if !evt.Expected || evt.Signal != tc.expectSignal || evt.ExitCode != tc.expectCode {
+				t.Errorf("unexpected exit event: %s expected=%v", evt, evt.Expected)
+			}
+		})
+	}
+}
+
+func TestSupervisorStopExited(t *testing.T) {
+	supervisor := NewSupervisor()
+	cmd := exec.Command("sh", "-c", "exit 0")
+	if err := cmd.Start(); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	supervisor.Watch(cmd)
+	time.Sleep(100 * time.Millisecond)
+	// stopping a process that already exited returns right away
+	if err := supervisor.Stop(cmd, types.ShutdownPolicy{Grace: time.Minute}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSupervisorSignalReaped(t *testing.T) {
+	supervisor := NewSupervisor()
+	cmd := exec.Command("sh", "-c", "exit 0")
+	if err := cmd.Start(); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	<-supervisor.Done(cmd)
+	// the pid may already belong to another process, it must not be signalled
+	if err := supervisor.Signal(cmd, syscall.SIGTERM); err != errReaped {
+		t.Errorf("expected errReaped, got %v", err)
+	}
+	<-supervisor.Exits()
+}
@@ -0,0 +1,225 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// errReaped is returned when signalling a process that was reaped
+var errReaped = errors.New("process was reaped")
+
+// ExitEvent describes how a supervised pocket-core process terminated
+type ExitEvent struct {
+	Cmd *exec.Cmd
+	// ExitCode is the exit status of the process, -1 if it was terminated by a signal
+	ExitCode int
+	// Signal is the signal that terminated the process, 0 if it exited on its own
+	Signal syscall.Signal
+	// Err is the error returned by cmd.Wait, nil on a clean exit
+	Err error
+	// Expected is true when the runner asked for the process to stop (upgrade or shutdown)
+	Expected bool
+}
+
+// Signaled reports whether the process was terminated by a signal
+func (e ExitEvent) Signaled() bool {
+	return e.Signal != 0
+}
+
+// Success reports whether the process exited with a zero status
+func (e ExitEvent) Success() bool {
+	return e.Err == nil && e.ExitCode == 0
+}
+
+// Reason maps the exit to the reason handed to the restart policy
+func (e ExitEvent) Reason() RestartReason {
+	if e.Success() {
+		return ReasonExit
+	}
+	return ReasonFailure
+}
+
+// Status is the status the runner should exit with to mirror the child, signals follow the shell convention 128+n
+func (e ExitEvent) Status() int {
+	if e.Signaled() {
+		return 128 + int(e.Signal)
+	}
+	if e.ExitCode < 0 {
+		return 1
+	}
+	return e.ExitCode
+}
+
+func (e ExitEvent) String() string {
+	pid := -1
+	if e.Cmd != nil && e.Cmd.Process != nil {
+		pid = e.Cmd.Process.Pid
+	}
+	if e.Signaled() {
+		return fmt.Sprintf("pid %d terminated by signal %s", pid, e.Signal)
+	}
+	if e.Err != nil && e.ExitCode < 0 {
+		return fmt.Sprintf("pid %d could not be reaped: %v", pid, e.Err)
+	}
+	return fmt.Sprintf("pid %d exited with status %d", pid, e.ExitCode)
+}
+
+// Supervisor reaps pocket-core processes and reports how they exited on a single channel
+type Supervisor struct {
+	exits    chan ExitEvent
+	expected map[*exec.Cmd]bool
+	// done is closed once the watched process was reaped
+	done map[*exec.Cmd]chan struct{}
+	// errs holds what cmd.Wait returned for the reaped processes
+	errs map[*exec.Cmd]error
+	// closed is closed by Close, exits are no longer sent once nobody reads them
+	closed    chan struct{}
+	closeOnce sync.Once
+	mu        sync.Mutex
+	// reaping is held while a process is reaped and while it is signalled,
+	// so a signal never reaches the pid of a reaped process, which the system may have reused
+	reaping sync.Mutex
+	reaped  map[*exec.Cmd]bool
+}
+
+// NewSupervisor returns a supervisor with no watched processes
+func NewSupervisor() *Supervisor {
+	return &Supervisor{
+		exits:    make(chan ExitEvent, 1),
+		expected: make(map[*exec.Cmd]bool),
+		done:     make(map[*exec.Cmd]chan struct{}),
+		errs:     make(map[*exec.Cmd]error),
+		closed:   make(chan struct{}),
+		reaped:   make(map[*exec.Cmd]bool),
+	}
+}
+
+// Exits is where an ExitEvent is sent for every watched process once it terminates
+func (s *Supervisor) Exits() <-chan ExitEvent {
+	return s.exits
+}
+
+// Close stops sending exits, the processes reaped afterwards are only reported by Done.
+// Closing a supervisor twice is a no-op.
+func (s *Supervisor) Close() {
+	s.closeOnce.Do(func() { close(s.closed) })
+}
+
+// Expect marks the next exit of cmd as requested by the runner, so it is not treated as a crash
+func (s *Supervisor) Expect(cmd *exec.Cmd) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expected[cmd] = true
+}
+
+// Unexpect undoes Expect, the next exit of cmd is handled like any other exit again
+func (s *Supervisor) Unexpect(cmd *exec.Cmd) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.expected, cmd)
+}
+
+// Done returns a channel closed once cmd was reaped, cmd is watched if it was not already
+func (s *Supervisor) Done(cmd *exec.Cmd) <-chan struct{} {
+	return s.watch(cmd)
+}
+
+// Err returns the error cmd.Wait returned, it is only meaningful once Done(cmd) is closed
+func (s *Supervisor) Err(cmd *exec.Cmd) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.errs[cmd]
+}
+
+// Watch waits for cmd in a new goroutine; cmd.Wait must not be called anywhere else.
+// Watching a process twice is a no-op.
+func (s *Supervisor) Watch(cmd *exec.Cmd) {
+	s.watch(cmd)
+}
+
+// Forget drops what the supervisor keeps about cmd once it was reaped, so replacing a crashing process
+// does not grow the supervisor. Done reports a forgotten process as reaped, Err no longer knows its error.
+// Forgetting a process that was not reaped is a no-op.
+func (s *Supervisor) Forget(cmd *exec.Cmd) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if done, ok := s.done[cmd]; !ok || !reaped(done) {
+		return
+	}
+	delete(s.done, cmd)
+	delete(s.errs, cmd)
+	delete(s.expected, cmd)
+	s.reaping.Lock()
+	delete(s.reaped, cmd)
+	s.reaping.Unlock()
+}
+
+// watch starts waiting for cmd unless it is already watched, returns a channel closed once cmd was reaped
+func (s *Supervisor) watch(cmd *exec.Cmd) <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if done, ok := s.done[cmd]; ok {
+		return done
+	}
+	if cmd.ProcessState != nil {
+		// reaped and forgotten, it must not be waited for again
+		done := make(chan struct{})
+		close(done)
+		return done
+	}
+	done := make(chan struct{})
+	s.done[cmd] = done
+	go func() {
+		// wait for the exit first, the pid stays reserved until it is reaped under the lock
+		exited := waitExited(cmd.Process.Pid)
+		if exited {
+			s.reaping.Lock()
+		}
+		err := cmd.Wait()
+		if !exited {
+			s.reaping.Lock()
+		}
+		s.reaped[cmd] = true
+		s.reaping.Unlock()
+		evt := exitEvent(cmd, err)
+		s.mu.Lock()
+		evt.Expected = s.expected[cmd]
+		delete(s.expected, cmd)
+		s.errs[cmd] = err
+		s.mu.Unlock()
+		close(done)
+		select {
+		case s.exits <- evt:
+		case <-s.closed:
+		}
+	}()
+	return done
+}
+
+// Signal sends sig to cmd, it returns errReaped instead once cmd was reaped
+func (s *Supervisor) Signal(cmd *exec.Cmd, sig os.Signal) error {
+	s.reaping.Lock()
+	defer s.reaping.Unlock()
+	if s.reaped[cmd] {
+		return errReaped
+	}
+	return cmd.Process.Signal(sig)
+}
+
+// exitEvent builds an ExitEvent out of the result of cmd.Wait
+func exitEvent(cmd *exec.Cmd, err error) ExitEvent {
+	evt := ExitEvent{Cmd: cmd, Err: err, ExitCode: -1}
+	if cmd.ProcessState == nil {
+		return evt
+	}
+	evt.ExitCode = cmd.ProcessState.ExitCode()
+	if status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		evt.Signal = status.Signal()
+	}
+	return evt
+}
@@ -0,0 +1,138 @@
+package runner
+
+import (
+	"os/exec"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSupervisor(t *testing.T) {
+	supervisor := NewSupervisor()
+	waitExit := func(t *testing.T) ExitEvent {
+		select {
+		case evt := <-supervisor.Exits():
+			return evt
+		case <-time.After(5 * time.Second):
+			t.Error("timed out waiting for the exit event")
+			t.FailNow()
+		}
+		return ExitEvent{}
+	}
+
+	t.Run("clean exit", func(t *testing.T) {
+		cmd := exec.Command("sh", "-c", "exit 0")
+		if err := cmd.Start(); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		supervisor.Watch(cmd)
+		evt := waitExit(t)
+		if !evt.Success() || evt.Reason() != ReasonExit || evt.Status() != 0 {
+			t.Errorf("unexpected exit event: %s", evt)
+		}
+	})
+	t.Run("exit status", func(t *testing.T) {
+		cmd := exec.Command("sh", "-c", "exit 3")
+		if err := cmd.Start(); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		supervisor.Watch(cmd)
+		evt := waitExit(t)
+		if evt.ExitCode != 3 || evt.Signaled() || evt.Reason() != ReasonFailure || evt.Status() != 3 {
+			t.Errorf("unexpected exit event: %s", evt)
+		}
+	})
+	t.Run("expected kill", func(t *testing.T) {
+		cmd := exec.Command("sleep", "10")
+		if err := cmd.Start(); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		supervisor.Watch(cmd)
+		supervisor.Expect(cmd)
+		if err := cmd.Process.Kill(); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		evt := waitExit(t)
+		if !evt.Expected || evt.Signal != syscall.SIGKILL || evt.Status() != 128+int(syscall.SIGKILL) {
+			t.Errorf("unexpected exit event: %s expected=%v", evt, evt.Expected)
+		}
+	})
+}
+
+func TestSupervisorClose(t *testing.T) {
+	supervisor := NewSupervisor()
+	before := runtime.NumGoroutine()
+	// nobody reads the exits, the second one cannot be buffered
+	for i := 0; i < 2; i++ {
+		cmd := exec.Command("sh", "-c", "exit 0")
+		if err := cmd.Start(); err != nil {
+			t.Error(err)
+			t.FailNow()
+		}
+		<-supervisor.Done(cmd)
+	}
+	supervisor.Close()
+	supervisor.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Errorf("expected the watchers to return once closed, %d goroutines left of %d", runtime.NumGoroutine(), before)
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// tracked is the number of entries the supervisor keeps about processes
+func tracked(s *Supervisor) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reaping.Lock()
+	defer s.reaping.Unlock()
+	return len(s.done) + len(s.errs) + len(s.expected) + len(s.reaped)
+}
+
+func TestSupervisorForget(t *testing.T) {
+	supervisor := NewSupervisor()
+	running := exec.Command("sleep", "10")
+	if err := running.Start(); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer running.Process.Kill()
+	supervisor.Watch(running)
+	// a process that was not reaped is kept
+	supervisor.Forget(running)
+	if reaped(supervisor.Done(running)) || tracked(supervisor) == 0 {
+		t.Error("expected the running process to be kept")
+	}
+
+	cmd := exec.Command("sh", "-c", "exit 3")
+	if err := cmd.Start(); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	supervisor.Expect(cmd)
+	<-supervisor.Done(cmd)
+	<-supervisor.Exits()
+	before := tracked(supervisor)
+	supervisor.Forget(cmd)
+	after := tracked(supervisor)
+	if after >= before {
+		t.Errorf("expected the entries of the reaped process to be dropped, %d left of %d", after, before)
+	}
+	// a forgotten process is neither waited for nor reported again
+	if !reaped(supervisor.Done(cmd)) || tracked(supervisor) != after {
+		t.Error("expected a forgotten process to be reported as reaped")
+	}
+	select {
+	case evt := <-supervisor.Exits():
+		t.Errorf("unexpected exit event: %s", evt)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -9,12 +9,23 @@ import (
 // We can now make any changes to the underlying directory without interferance and leave it
 // in a state, so we can make a proper restart
 func Upgrade(cfg *types.Config, info *types.UpgradeInfo) error {
+	_, err := Switch(cfg, info)
+	return err
+}
+
+// Switch performs the upgrade like Upgrade and returns what it replaced, so the upgrade can be rolled back
+func Switch(cfg *types.Config, info *types.UpgradeInfo) (types.RollbackPoint, error) {
+	point := types.RollbackPoint{Previous: cfg.CurrentUpgrade()}
 	err := types.CheckBinary(cfg.UpgradeBin(info.Name))
 
 	// Simplest case is to switch the link
 	if err != nil {
-		return errors.Wrapf(err, "No binary available for upgrade")
+		return point, errors.Wrapf(err, "No binary available for upgrade")
+	}
+	// snapshot the data so a bad migration can be rolled back
+	if point.Backup, err = BackupData(cfg, info); err != nil {
+		return point, errors.Wrapf(err, "could not back up data before upgrade %s, set DAEMON_SKIP_BACKUP=on to upgrade without a backup", info.Name)
 	}
 	// we have the binary - do it
-	return cfg.SetCurrentUpgrade(info.Name)
+	return point, cfg.SetCurrentUpgrade(info.Name)
 }
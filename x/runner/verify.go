@@ -0,0 +1,205 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pokt-network/pocket-runner/internal/types"
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	checksumsFile      = "SHA256SUMS"
+	signatureExtension = ".minisig"
+	checksumPrefix     = "sha256:"
+	// maxSidecarSize bounds the checksum and signature files, they are a few hundred bytes at most
+	maxSidecarSize = 1 << 20
+)
+
+var (
+	// ErrChecksumMismatch occurs when an artifact does not match its SHA-256 checksum
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	// ErrNoChecksum occurs when no checksum is known for an artifact
+	ErrNoChecksum = errors.New("no checksum available")
+	// ErrBadSignature occurs when an artifact is not signed by any of the trusted keys
+	ErrBadSignature = errors.New("invalid signature")
+)
+
+// sidecarClient fetches the checksum and signature files
+var sidecarClient = &http.Client{Timeout: 30 * time.Second}
+
+// VerifyArtifact checks the artifact downloaded from link to file before it is unpacked or executed.
+// The SHA-256 comes from the upgrade plan, or from the SHA256SUMS file next to the artifact.
+// If the verify policy has public keys, the detached minisign signature at <link>.minisig must also be valid.
+func VerifyArtifact(cfg *types.Config, info *types.UpgradeInfo, link, file string) error {
+	if cfg.Verify.SkipChecksum {
+		log.Printf("WARNING: not verifying the checksum of %s\n", link)
+	} else {
+		expected := info.Checksum
+		if expected == "" {
+			var err error
+			if expected, err = FetchChecksum(link); err != nil {
+				return err
+			}
+		}
+		if err := VerifyChecksum(file, expected); err != nil {
+			return errors.Wrapf(err, "artifact %s", link)
+		}
+	}
+
+	keys, err := cfg.Verify.SigningKeys()
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+	sig, err := fetchSidecar(link + signatureExtension)
+	if err != nil {
+		return errors.Wrapf(ErrBadSignature, "no signature for %s: %v", link, err)
+	}
+	bz, err := ioutil.ReadFile(file)
+	if err != nil {
+		return errors.Wrapf(err, "cannot read %s", file)
+	}
+	return errors.Wrapf(VerifySignature(bz, sig, keys), "artifact %s", link)
+}
+
+// VerifyChecksum compares the SHA-256 of file with expected, a hex digest optionally prefixed with "sha256:"
+func VerifyChecksum(file, expected string) error {
+	expected = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(expected), checksumPrefix))
+	if len(expected) != hex.EncodedLen(sha256.Size) {
+		return errors.Wrapf(ErrNoChecksum, "%q is not a SHA-256 checksum", expected)
+	}
+	actual, err := fileSHA256(file)
+	if err != nil {
+		return err
+	}
+	if actual != expected {
+		return errors.Wrapf(ErrChecksumMismatch, "expected %s, got %s", expected, actual)
+	}
+	return nil
+}
+
+// fileSHA256 returns the hex SHA-256 of file
+func fileSHA256(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot open %s", file)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", errors.Wrapf(err, "cannot hash %s", file)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// FetchChecksum looks the artifact at link up in the SHA256SUMS file of the same directory
+func FetchChecksum(link string) (string, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid artifact url %s", link)
+	}
+	name := path.Base(u.Path)
+	u.Path = path.Join(path.Dir(u.Path), checksumsFile)
+	sumsLink := u.String()
+	sums, err := fetchSidecar(sumsLink)
+	if err != nil {
+		return "", errors.Wrapf(ErrNoChecksum, "no checksum in the upgrade plan and %s: %v", sumsLink, err)
+	}
+	checksums, err := ParseChecksums(bytes.NewReader(sums))
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot parse %s", sumsLink)
+	}
+	checksum, ok := checksums[name]
+	if !ok {
+		return "", errors.Wrapf(ErrNoChecksum, "%s is not listed in %s", name, sumsLink)
+	}
+	return checksum, nil
+}
+
+// ParseChecksums reads a SHA256SUMS file as written by sha256sum: "<hex digest>  <file name>" per line
+func ParseChecksums(r io.Reader) (map[string]string, error) {
+	checksums := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, errors.Errorf("line %d: expected a checksum and a file name", line)
+		}
+		// binary mode entries are prefixed with a star
+		checksums[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+	}
+	return checksums, scanner.Err()
+}
+
+// VerifySignature checks the minisign signature sig of content against the trusted keys.
+// Both the legacy (Ed) and the prehashed (ED) minisign signatures are accepted.
+func VerifySignature(content, sig []byte, keys []types.SigningKey) error {
+	lines := strings.Split(strings.TrimSpace(string(sig)), "\n")
+	if len(lines) < 4 {
+		return errors.Wrap(ErrBadSignature, "expected a minisign signature file")
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(raw) != 2+8+ed25519.SignatureSize {
+		return errors.Wrap(ErrBadSignature, "malformed signature")
+	}
+	algorithm, keyID, signature := string(raw[:2]), raw[2:10], raw[10:]
+	trustedComment := strings.TrimPrefix(strings.TrimSpace(lines[2]), "trusted comment: ")
+	globalSignature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || len(globalSignature) != ed25519.SignatureSize {
+		return errors.Wrap(ErrBadSignature, "malformed trusted comment signature")
+	}
+
+	message := content
+	switch algorithm {
+	case "Ed":
+	case "ED":
+		digest := blake2b.Sum512(content)
+		message = digest[:]
+	default:
+		return errors.Wrapf(ErrBadSignature, "unsupported signature algorithm %q", algorithm)
+	}
+	for _, key := range keys {
+		if !bytes.Equal(key.ID[:], keyID) {
+			continue
+		}
+		if !ed25519.Verify(key.Key, message, signature) {
+			return errors.Wrapf(ErrBadSignature, "signature does not match key %X", key.ID)
+		}
+		if !ed25519.Verify(key.Key, append(append([]byte{}, signature...), trustedComment...), globalSignature) {
+			return errors.Wrapf(ErrBadSignature, "trusted comment does not match key %X", key.ID)
+		}
+		return nil
+	}
+	return errors.Wrapf(ErrBadSignature, "signed by the unknown key %X", keyID)
+}
+
+// fetchSidecar downloads a small file published next to an artifact
+func fetchSidecar(link string) ([]byte, error) {
+	resp, err := sidecarClient.Get(link)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("GET %s: %s", link, resp.Status)
+	}
+	return ioutil.ReadAll(io.LimitReader(resp.Body, maxSidecarSize))
+}
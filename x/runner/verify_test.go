@@ -0,0 +1,193 @@
+package runner
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/pokt-network/pocket-runner/internal/types"
+	"golang.org/x/crypto/blake2b"
+)
+
+// testSigner signs content the way minisign does
+type testSigner struct {
+	id   [8]byte
+	priv ed25519.PrivateKey
+	pub  string
+}
+
+func newTestSigner(t *testing.T, id byte) testSigner {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	s := testSigner{id: [8]byte{id}, priv: priv}
+	s.pub = base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), s.id[:]...), pub...))
+	return s
+}
+
+func (s testSigner) sign(content []byte, prehash bool) []byte {
+	algorithm, message := "Ed", content
+	if prehash {
+		digest := blake2b.Sum512(content)
+		algorithm, message = "ED", digest[:]
+	}
+	sig := ed25519.Sign(s.priv, message)
+	comment := "timestamp:1587000000"
+	global := ed25519.Sign(s.priv, append(append([]byte{}, sig...), comment...))
+	raw := append(append([]byte(algorithm), s.id[:]...), sig...)
+	return []byte(fmt.Sprintf("untrusted comment: signature from test key\n%s\ntrusted comment: %s\n%s\n",
+		base64.StdEncoding.EncodeToString(raw), comment, base64.StdEncoding.EncodeToString(global)))
+}
+
+func sha256Hex(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestParseChecksums(t *testing.T) {
+	sums := "# release checksums\n" +
+		"E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855  pocket-core-linux-amd64.tar.gz\n" +
+		"\n" +
+		"2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae *RC-0.2.0.zip\n"
+	checksums, err := ParseChecksums(strings.NewReader(sums))
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	if len(checksums) != 2 ||
+		checksums["pocket-core-linux-amd64.tar.gz"] != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" ||
+		checksums["RC-0.2.0.zip"] != "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae" {
+		t.Errorf("unexpected checksums %v", checksums)
+	}
+	if _, err := ParseChecksums(strings.NewReader("only-a-digest\n")); err == nil {
+		t.Error("expected an error for a malformed line")
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	dir, err := ioutil.TempDir("", "verify")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "RC-0.2.0.zip")
+	content := []byte("release")
+	if err := ioutil.WriteFile(file, content, 0644); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	cases := map[string]struct {
+		checksum  string
+		expectErr error
+	}{
+		"match":        {checksum: sha256Hex(content)},
+		"prefixed":     {checksum: "sha256:" + strings.ToUpper(sha256Hex(content))},
+		"mismatch":     {checksum: sha256Hex([]byte("tampered")), expectErr: ErrChecksumMismatch},
+		"not a sha256": {checksum: "abcd", expectErr: ErrNoChecksum},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if err := VerifyChecksum(file, tc.checksum); errors.Cause(err) != tc.expectErr {
+				t.Errorf("expected %v, got %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	trusted, other := newTestSigner(t, 1), newTestSigner(t, 2)
+	key, err := types.ParseSigningKey("untrusted comment: minisign public key\n" + trusted.pub)
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	content := []byte("release")
+	cases := map[string]struct {
+		content   []byte
+		sig       []byte
+		expectErr error
+	}{
+		"legacy":      {content: content, sig: trusted.sign(content, false)},
+		"prehashed":   {content: content, sig: trusted.sign(content, true)},
+		"tampered":    {content: []byte("tampered"), sig: trusted.sign(content, true), expectErr: ErrBadSignature},
+		"unknown key": {content: content, sig: other.sign(content, true), expectErr: ErrBadSignature},
+		"malformed":   {content: content, sig: []byte("not a signature"), expectErr: ErrBadSignature},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if err := VerifySignature(tc.content, tc.sig, []types.SigningKey{key}); errors.Cause(err) != tc.expectErr {
+				t.Errorf("expected %v, got %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestVerifyArtifact(t *testing.T) {
+	signer := newTestSigner(t, 1)
+	content := []byte("release")
+	files := map[string][]byte{
+		"/releases/RC-0.2.0.zip":         content,
+		"/releases/SHA256SUMS":           []byte(sha256Hex(content) + "  RC-0.2.0.zip\n"),
+		"/releases/RC-0.2.0.zip.minisig": signer.sign(content, true),
+		"/unlisted/RC-0.2.0.zip":         content,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bz, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(bz)
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "verify")
+	if err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "RC-0.2.0.zip")
+	if err := ioutil.WriteFile(file, content, 0644); err != nil {
+		t.Error(err)
+		t.FailNow()
+	}
+
+	cases := map[string]struct {
+		verify    types.VerifyPolicy
+		checksum  string
+		path      string
+		expectErr error
+	}{
+		"checksums file":     {path: "/releases/RC-0.2.0.zip"},
+		"plan checksum":      {path: "/unlisted/RC-0.2.0.zip", checksum: sha256Hex(content)},
+		"wrong plan":         {path: "/releases/RC-0.2.0.zip", checksum: sha256Hex([]byte("other")), expectErr: ErrChecksumMismatch},
+		"no checksum":        {path: "/unlisted/RC-0.2.0.zip", expectErr: ErrNoChecksum},
+		"unsafe skip":        {path: "/unlisted/RC-0.2.0.zip", verify: types.VerifyPolicy{SkipChecksum: true}},
+		"signed":             {path: "/releases/RC-0.2.0.zip", verify: types.VerifyPolicy{PublicKeys: []string{signer.pub}}},
+		"missing signature":  {path: "/unlisted/RC-0.2.0.zip", checksum: sha256Hex(content), verify: types.VerifyPolicy{PublicKeys: []string{signer.pub}}, expectErr: ErrBadSignature},
+		"signed by stranger": {path: "/releases/RC-0.2.0.zip", verify: types.VerifyPolicy{PublicKeys: []string{newTestSigner(t, 1).pub}}, expectErr: ErrBadSignature},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cfg := &types.Config{Name: "test-runnerd", Verify: tc.verify}
+			info := &types.UpgradeInfo{Name: "RC-0.2.0", Checksum: tc.checksum}
+			if err := VerifyArtifact(cfg, info, server.URL+tc.path, file); errors.Cause(err) != tc.expectErr {
+				t.Errorf("expected %v, got %v", tc.expectErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+//go:build linux
+// +build linux
+
+package runner
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// pPID selects a single process in waitid
+const pPID = 1
+
+// waitExited blocks until the process pid exited without reaping it, so its pid is not reused until it is reaped.
+// It reports whether it could wait, the process is left waitable either way.
+func waitExited(pid int) bool {
+	var siginfo [128]byte
+	for {
+		_, _, errno := syscall.Syscall6(syscall.SYS_WAITID, pPID, uintptr(pid), uintptr(unsafe.Pointer(&siginfo[0])), syscall.WEXITED|syscall.WNOWAIT, 0, 0)
+		if errno != syscall.EINTR {
+			return errno == 0
+		}
+	}
+}
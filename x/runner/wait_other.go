@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package runner
+
+// waitExited cannot wait for a process without reaping it on this system, the process is reaped
+// before the supervisor takes its lock, so a signal may still race with the reap
+func waitExited(pid int) bool {
+	return false
+}